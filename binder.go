@@ -0,0 +1,229 @@
+package goka
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+type (
+	BindingError struct {
+		Field string
+		Err   error
+	}
+
+	valuesFunc func(name string) []string
+)
+
+var (
+	errBindTarget = errors.New("binding target must be a pointer to a struct")
+
+	timeType = reflect.TypeOf(time.Time{})
+)
+
+func (e *BindingError) Error() string {
+	return "invalid value for field " + strconv.Quote(e.Field) + ": " + e.Err.Error()
+}
+
+func (c *Context) Bind(i interface{}) (err error) {
+	req := &c.requestCtx.Request
+	body := req.Body()
+	if len(body) == 0 {
+		switch string(req.Header.Method()) {
+		case GET, HEAD, DELETE:
+			err = c.BindQuery(i)
+		}
+	} else {
+		ct := string(req.Header.ContentType())
+		switch {
+		case strings.HasPrefix(ct, ApplicationJSON):
+			if err = json.Unmarshal(body, i); err != nil {
+				err = NewHTTPError(fasthttp.StatusBadRequest, err.Error())
+			}
+		case strings.HasPrefix(ct, ApplicationXML):
+			if err = xml.Unmarshal(body, i); err != nil {
+				err = NewHTTPError(fasthttp.StatusBadRequest, err.Error())
+			}
+		case strings.HasPrefix(ct, ApplicationForm):
+			err = bindData(i, "form", argsValues(c.requestCtx.PostArgs()))
+		case strings.HasPrefix(ct, MultipartForm):
+			f, ferr := c.requestCtx.MultipartForm()
+			if ferr != nil {
+				return NewHTTPError(fasthttp.StatusBadRequest, ferr.Error())
+			}
+			err = bindData(i, "form", mapValues(f.Value))
+		default:
+			err = ErrUnsupportedMediaType
+		}
+	}
+	if err != nil {
+		return
+	}
+	if err = ValidateStruct(i); err != nil {
+		return
+	}
+	if v, ok := i.(Validator); ok {
+		err = v.Validate()
+	}
+	return
+}
+
+// BindParams binds path parameters to the fields of i with a param tag.
+// Values are copied, as path parameters share the request's buffer.
+func (c *Context) BindParams(i interface{}) error {
+	return bindData(i, "param", func(name string) []string {
+		for n, pn := range c.names {
+			if pn == name {
+				return []string{c.values[n]}
+			}
+		}
+		return nil
+	})
+}
+
+func (c *Context) BindQuery(i interface{}) error {
+	return bindData(i, "query", argsValues(c.requestCtx.QueryArgs()))
+}
+
+func (c *Context) BindHeaders(i interface{}) error {
+	return bindData(i, "header", func(name string) []string {
+		if v := c.requestCtx.Request.Header.Peek(name); len(v) > 0 {
+			return []string{string(v)}
+		}
+		return nil
+	})
+}
+
+func argsValues(args *fasthttp.Args) valuesFunc {
+	return func(name string) []string {
+		vs := args.PeekMulti(name)
+		if len(vs) == 0 {
+			return nil
+		}
+		s := make([]string, len(vs))
+		for i, v := range vs {
+			s[i] = string(v)
+		}
+		return s
+	}
+}
+
+func mapValues(m map[string][]string) valuesFunc {
+	return func(name string) []string {
+		return m[name]
+	}
+}
+
+func bindData(i interface{}, tag string, values valuesFunc) error {
+	v := reflect.ValueOf(i)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return errBindTarget
+	}
+	return bindStruct(v.Elem(), tag, values)
+}
+
+func bindStruct(v reflect.Value, tag string, values valuesFunc) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tf := t.Field(i)
+		vf := v.Field(i)
+		if !vf.CanSet() {
+			continue
+		}
+		if tf.Anonymous && vf.Kind() == reflect.Struct && vf.Type() != timeType {
+			if err := bindStruct(vf, tag, values); err != nil {
+				return err
+			}
+			continue
+		}
+		name := tf.Tag.Get(tag)
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = tf.Name
+		}
+		vs := values(name)
+		if len(vs) == 0 {
+			continue
+		}
+		if err := setField(vf, vs, tf.Tag.Get("layout")); err != nil {
+			return &BindingError{Field: name, Err: err}
+		}
+	}
+	return nil
+}
+
+func setField(v reflect.Value, vs []string, layout string) error {
+	if v.Kind() == reflect.Slice {
+		s := reflect.MakeSlice(v.Type(), len(vs), len(vs))
+		for i, val := range vs {
+			if err := setValue(s.Index(i), val, layout); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+	return setValue(v, vs[0], layout)
+}
+
+func setValue(v reflect.Value, s, layout string) error {
+	if v.Kind() == reflect.Ptr {
+		p := reflect.New(v.Type().Elem())
+		if err := setValue(p.Elem(), s, layout); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+	if v.Type() == timeType {
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return errors.New("unsupported type " + v.Type().String())
+	}
+	return nil
+}
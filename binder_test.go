@@ -0,0 +1,138 @@
+package goka
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+type bindUser struct {
+	ID    int      `json:"id" xml:"id" form:"id" query:"id"`
+	Name  string   `json:"name" xml:"name" form:"name" query:"name"`
+	Tags  []string `json:"tags" xml:"tags" form:"tag" query:"tag"`
+	Admin bool     `json:"admin" xml:"admin" form:"admin" query:"admin"`
+}
+
+func (u *bindUser) Validate() error {
+	if u.Name == "" {
+		return errors.New("name required")
+	}
+	return nil
+}
+
+func newBindContext(method, uri, ct, body string) *Context {
+	rCtx := new(fasthttp.RequestCtx)
+	rCtx.Request.Header.SetMethod(method)
+	rCtx.Request.SetRequestURI(uri)
+	if ct != "" {
+		rCtx.Request.Header.SetContentType(ct)
+	}
+	rCtx.Request.SetBodyString(body)
+	return NewContext(rCtx, New())
+}
+
+func TestContextBind(t *testing.T) {
+	tests := []struct {
+		method, uri, ct, body string
+	}{
+		{POST, "/", ApplicationJSONCharsetUTF8, `{"id":1,"name":"goka","tags":["a","b"],"admin":true}`},
+		{POST, "/", ApplicationXML, `<user><id>1</id><name>goka</name><tags>a</tags><tags>b</tags><admin>true</admin></user>`},
+		{POST, "/", ApplicationForm, `id=1&name=goka&tag=a&tag=b&admin=true`},
+		{GET, "/?id=1&name=goka&tag=a&tag=b&admin=true", "", ""},
+	}
+	for _, tt := range tests {
+		u := new(bindUser)
+		if err := newBindContext(tt.method, tt.uri, tt.ct, tt.body).Bind(u); err != nil {
+			t.Errorf("%s %s: %v", tt.method, tt.ct, err)
+			continue
+		}
+		if u.ID != 1 || u.Name != "goka" || len(u.Tags) != 2 || u.Tags[1] != "b" || !u.Admin {
+			t.Errorf("%s %s: unexpected %+v", tt.method, tt.ct, u)
+		}
+	}
+}
+
+func TestContextBindErrors(t *testing.T) {
+	u := new(bindUser)
+	if err := newBindContext(POST, "/", TextPlain, "x").Bind(u); err != ErrUnsupportedMediaType {
+		t.Errorf("expected ErrUnsupportedMediaType, got %v", err)
+	}
+	err := newBindContext(POST, "/", ApplicationJSON, "{").Bind(u)
+	if he, ok := err.(*HTTPError); !ok || he.Code() != fasthttp.StatusBadRequest {
+		t.Errorf("expected 400, got %v", err)
+	}
+	if err := newBindContext(POST, "/", ApplicationJSON, `{"id":1}`).Bind(u); err == nil || err.Error() != "name required" {
+		t.Errorf("expected validation error, got %v", err)
+	}
+}
+
+func TestContextBindParamsQueryHeaders(t *testing.T) {
+	type params struct {
+		ID     int64      `param:"id"`
+		Page   *int       `query:"page"`
+		Score  float64    `query:"score"`
+		Since  time.Time  `query:"since" layout:"2006-01-02"`
+		Sort   []string   `query:"sort"`
+		Tenant string     `header:"X-Tenant"`
+		Debug  bool       `header:"X-Debug"`
+		Until  *time.Time `query:"until"`
+	}
+
+	g := New()
+	var r params
+	g.Get("/users/:id", func(c *Context) error {
+		r = params{}
+		if err := c.BindParams(&r); err != nil {
+			return err
+		}
+		if err := c.BindQuery(&r); err != nil {
+			return err
+		}
+		return c.BindHeaders(&r)
+	})
+
+	rCtx := new(fasthttp.RequestCtx)
+	rCtx.Request.SetRequestURI("/users/42?page=3&score=1.5&since=2016-02-18&sort=a&sort=b&until=2016-02-18T10:00:00Z")
+	rCtx.Request.Header.Set("X-Tenant", "acme")
+	rCtx.Request.Header.Set("X-Debug", "true")
+	g.Serve(rCtx)
+	if rCtx.Response.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("unexpected response %d %q", rCtx.Response.StatusCode(), rCtx.Response.Body())
+	}
+	if r.ID != 42 || r.Page == nil || *r.Page != 3 || r.Score != 1.5 || r.Since.Day() != 18 ||
+		len(r.Sort) != 2 || r.Tenant != "acme" || !r.Debug || r.Until == nil || r.Until.Hour() != 10 {
+		t.Errorf("unexpected %+v", r)
+	}
+
+	rCtx = request(g, GET, "/users/abc")
+	if rCtx.Response.StatusCode() != fasthttp.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rCtx.Response.StatusCode())
+	}
+	if !strings.Contains(string(rCtx.Response.Body()), `"id"`) {
+		t.Errorf("expected field name in body, got %q", rCtx.Response.Body())
+	}
+}
+
+func TestContextBindParamsCopiesValues(t *testing.T) {
+	type params struct {
+		Name string `param:"name"`
+	}
+	g := New()
+	var r params
+	g.Get("/users/:name", func(c *Context) error {
+		return c.BindParams(&r)
+	})
+
+	rCtx := new(fasthttp.RequestCtx)
+	rCtx.Request.SetRequestURI("/users/alice")
+	g.Serve(rCtx)
+	// Reusing the request overwrites its path buffer.
+	rCtx.Request.SetRequestURI("/users/bobby")
+	rCtx.Request.URI().Path()
+	if r.Name != "alice" {
+		t.Errorf("expected bound value to survive the request, got %q", r.Name)
+	}
+}
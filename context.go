@@ -3,6 +3,8 @@ package goka
 import (
 	"bytes"
 	"encoding/json"
+	"io"
+	"strconv"
 
 	"golang.org/x/net/context"
 
@@ -19,8 +21,20 @@ type (
 		query      *fasthttp.Args
 		store      store
 		goka       *Goka
+		group      *Group
+		node       *node
+		requestID  string
+		abandoned  bool
 	}
 	store map[string]interface{}
+
+	// bodyStream is the response body set by Context.Stream. Once detached
+	// by TransformBodyStream it no longer closes the underlying reader.
+	bodyStream struct {
+		io.Reader
+		closers  []io.Reader
+		detached bool
+	}
 )
 
 func NewContext(reqCtx *fasthttp.RequestCtx, g *Goka) *Context {
@@ -70,6 +84,74 @@ func (c *Context) Query(name string) string {
 	return string(c.query.Peek(name))
 }
 
+func (c *Context) ParamInt(name string) (int, error) {
+	n, err := strconv.Atoi(c.ParamByName(name))
+	if err != nil {
+		return 0, invalidParam(name)
+	}
+	return n, nil
+}
+
+func (c *Context) ParamInt64(name string) (int64, error) {
+	n, err := strconv.ParseInt(c.ParamByName(name), 10, 64)
+	if err != nil {
+		return 0, invalidParam(name)
+	}
+	return n, nil
+}
+
+func (c *Context) ParamUint(name string) (uint, error) {
+	n, err := strconv.ParseUint(c.ParamByName(name), 10, 0)
+	if err != nil {
+		return 0, invalidParam(name)
+	}
+	return uint(n), nil
+}
+
+func (c *Context) ParamBool(name string) (bool, error) {
+	b, err := strconv.ParseBool(c.ParamByName(name))
+	if err != nil {
+		return false, invalidParam(name)
+	}
+	return b, nil
+}
+
+func (c *Context) ParamUUID(name string) (UUID, error) {
+	u, err := ParseUUID(c.ParamByName(name))
+	if err != nil {
+		return u, invalidParam(name)
+	}
+	return u, nil
+}
+
+func (c *Context) QueryInt(name string) (int, error) {
+	n, err := strconv.Atoi(c.Query(name))
+	if err != nil {
+		return 0, invalidQuery(name)
+	}
+	return n, nil
+}
+
+func (c *Context) QueryBool(name string) (bool, error) {
+	b, err := strconv.ParseBool(c.Query(name))
+	if err != nil {
+		return false, invalidQuery(name)
+	}
+	return b, nil
+}
+
+func (c *Context) QueryStrings(name string) []string {
+	if c.query == nil {
+		c.query = c.requestCtx.URI().QueryArgs()
+	}
+	vs := c.query.PeekMulti(name)
+	s := make([]string, len(vs))
+	for i, v := range vs {
+		s[i] = string(v)
+	}
+	return s
+}
+
 func (c *Context) Form(name string) string {
 	return string(c.requestCtx.FormValue(name))
 }
@@ -135,13 +217,54 @@ func (c *Context) JSONIndent(code int, i interface{}, prefix string, indent stri
 	return
 }
 
+// Stream sends the content of r as the response body. r is read while the
+// response is written and closed afterwards if it is an io.Closer.
+func (c *Context) Stream(code int, contentType string, r io.Reader) error {
+	c.requestCtx.SetContentType(contentType)
+	c.requestCtx.SetStatusCode(code)
+	c.requestCtx.SetBodyStream(&bodyStream{Reader: r, closers: []io.Reader{r}}, -1)
+	return nil
+}
+
+// TransformBodyStream replaces a response body set by Stream with the
+// reader f returns for it, for example to compress it. Both readers are
+// closed after the response is written. It reports false, without calling
+// f, when the body was not set by Stream.
+func (c *Context) TransformBodyStream(f func(io.Reader) io.Reader) bool {
+	bs, ok := c.requestCtx.Response.BodyStream().(*bodyStream)
+	if !ok || bs.detached {
+		return false
+	}
+	bs.detached = true
+	r := f(bs.Reader)
+	c.requestCtx.Response.SetBodyStream(&bodyStream{
+		Reader:  r,
+		closers: append([]io.Reader{r}, bs.closers...),
+	}, -1)
+	return true
+}
+
+func (s *bodyStream) Close() (err error) {
+	if s.detached {
+		return nil
+	}
+	for _, r := range s.closers {
+		if cl, ok := r.(io.Closer); ok {
+			if cerr := cl.Close(); err == nil {
+				err = cerr
+			}
+		}
+	}
+	return
+}
+
 func (c *Context) NoContent(code int) error {
 	c.requestCtx.SetStatusCode(code)
 	return nil
 }
 
 func (c *Context) Redirect(code int, url string) error {
-	if code < fasthttp.StatusMovedPermanently || code > fasthttp.StatusTemporaryRedirect {
+	if code < fasthttp.StatusMovedPermanently || code > fasthttp.StatusPermanentRedirect {
 		return ErrInvalidRedirectCode
 	}
 	c.requestCtx.Redirect(url, code)
@@ -149,16 +272,54 @@ func (c *Context) Redirect(code int, url string) error {
 }
 
 func (c *Context) Error(err error) {
-	c.goka.httpErrorHandler(err, c)
+	c.goka.errorHandler(c.group)(err, c)
+}
+
+// RequestID returns the ID set by SetRequestID, usually by the RequestID
+// middleware, for correlating logs across services.
+func (c *Context) RequestID() string {
+	return c.requestID
+}
+
+func (c *Context) SetRequestID(id string) {
+	c.requestID = id
 }
 
 func (c *Context) Goka() *Goka {
 	return c.goka
 }
 
+func invalidParam(name string) *HTTPError {
+	return NewHTTPError(fasthttp.StatusBadRequest, "invalid path parameter "+strconv.Quote(name))
+}
+
+func invalidQuery(name string) *HTTPError {
+	return NewHTTPError(fasthttp.StatusBadRequest, "invalid query parameter "+strconv.Quote(name))
+}
+
+// Route returns the route matched by the request, or nil.
+func (c *Context) Route() *Route {
+	if c.node == nil {
+		return nil
+	}
+	m := string(c.requestCtx.Method())
+	if r := c.node.findRoute(m); r != nil || m != HEAD {
+		return r
+	}
+	return c.node.findRoute(GET)
+}
+
 func (c *Context) reset(rCtx *fasthttp.RequestCtx, g *Goka) {
+	c.Context = context.Background()
 	c.requestCtx = rCtx
 	c.query = nil
 	c.store = nil
 	c.goka = g
+	// Routing state is cleared so that pre-routing middleware and their
+	// error handlers do not see the previous request's route.
+	c.path = ""
+	c.names = nil
+	c.node = nil
+	c.group = nil
+	c.requestID = ""
 }
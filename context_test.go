@@ -0,0 +1,89 @@
+package goka
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestContextTypedParams(t *testing.T) {
+	g := New()
+	g.Get("/items/:id/:flag/:uuid", func(c *Context) error {
+		id, err := c.ParamInt("id")
+		if err != nil {
+			return err
+		}
+		flag, err := c.ParamBool("flag")
+		if err != nil {
+			return err
+		}
+		u, err := c.ParamUUID("uuid")
+		if err != nil {
+			return err
+		}
+		page, err := c.QueryInt("page")
+		if err != nil {
+			return err
+		}
+		if id != 7 || !flag || page != 2 || u.String() != "6ba7b810-9dad-11d1-80b4-00c04fd430c8" {
+			t.Errorf("unexpected values %d %v %d %s", id, flag, page, u)
+		}
+		if s := c.QueryStrings("tag"); len(s) != 2 || s[0] != "a" || s[1] != "b" {
+			t.Errorf("unexpected tags %v", s)
+		}
+		return c.NoContent(fasthttp.StatusOK)
+	})
+
+	rCtx := request(g, GET, "/items/7/true/6ba7b810-9dad-11d1-80b4-00c04fd430c8?page=2&tag=a&tag=b")
+	if rCtx.Response.StatusCode() != fasthttp.StatusOK {
+		t.Errorf("expected 200, got %d %q", rCtx.Response.StatusCode(), rCtx.Response.Body())
+	}
+	for _, path := range []string{
+		"/items/x/true/6ba7b810-9dad-11d1-80b4-00c04fd430c8?page=2",
+		"/items/7/maybe/6ba7b810-9dad-11d1-80b4-00c04fd430c8?page=2",
+		"/items/7/true/6ba7b810-9dad-11d1-80b4-00c04fd430cz?page=2",
+		"/items/7/true/6ba7b810-9dad-11d1-80b4-00c04fd430c8?page=x",
+	} {
+		rCtx = request(g, GET, path)
+		if rCtx.Response.StatusCode() != fasthttp.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, rCtx.Response.StatusCode())
+		}
+	}
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestContextStream(t *testing.T) {
+	src := &closeRecorder{Reader: strings.NewReader("hello")}
+	g := New()
+	g.Get("/", func(c *Context) error {
+		if err := c.Stream(fasthttp.StatusOK, TextPlain, src); err != nil {
+			return err
+		}
+		if !c.TransformBodyStream(func(r io.Reader) io.Reader {
+			b, _ := io.ReadAll(r)
+			return bytes.NewReader(bytes.ToUpper(b))
+		}) {
+			t.Error("expected stream body to be transformable")
+		}
+		return nil
+	})
+	rCtx := request(g, GET, "/")
+	if body := string(rCtx.Response.Body()); body != "HELLO" {
+		t.Errorf("expected transformed body, got %q", body)
+	}
+	if !src.closed {
+		t.Error("expected original stream to be closed")
+	}
+}
@@ -1,39 +1,61 @@
 package goka
 
 import (
-	"bytes"
 	"errors"
-	"fmt"
 	"io"
+	"net"
 	"reflect"
 	"runtime"
 	"sync"
+	"time"
+	"unsafe"
 
 	"github.com/valyala/fasthttp"
 )
 
 type (
 	Goka struct {
-		prefix                  string
+		premiddleware           []MiddlewareFunc
 		middleware              []MiddlewareFunc
+		pre                     HandlerFunc
 		maxParam                *int
 		defaultHTTPErrorHandler HTTPErrorHandler
 		httpErrorHandler        HTTPErrorHandler
+		notFoundHandler         HandlerFunc
+		methodNotAllowedHandler HandlerFunc
 		renderer                Renderer
 		pool                    sync.Pool
 		debug                   bool
 		router                  *Router
+		config                  Config
+		configPending           bool
+		mu                      sync.Mutex
+		server                  *fasthttp.Server
+		running                 bool
+		listener                net.Listener
+		onStart                 []func()
+		onShutdown              []func()
 	}
 
 	Route struct {
-		Method  string
-		Path    string
-		Handler Handler
+		Method     string
+		Path       string
+		Handler    Handler
+		name       string
+		router     *Router
+		node       *node
+		group      *Group
+		handler    HandlerFunc
+		middleware []MiddlewareFunc
+		meta       map[string]interface{}
+		timeout    time.Duration
+		bodyLimit  int
 	}
 
 	HTTPError struct {
 		code    int
 		message string
+		header  map[string]string
 	}
 
 	Middleware interface{}
@@ -60,6 +82,7 @@ const (
 )
 
 var (
+	// methods are the methods registered by Any.
 	methods = [...]string{
 		DELETE,
 		GET,
@@ -70,6 +93,20 @@ var (
 		PUT,
 	}
 
+	// standardMethods are the methods with a dedicated handler slot, in
+	// the order they are listed in the Allow header.
+	standardMethods = [...]string{
+		CONNECT,
+		DELETE,
+		GET,
+		HEAD,
+		OPTIONS,
+		PATCH,
+		POST,
+		PUT,
+		TRACE,
+	}
+
 	ErrUnsupportedMediaType  = NewHTTPError(fasthttp.StatusUnsupportedMediaType)
 	ErrRendererNotRegistered = errors.New("renderer not registered")
 	ErrInvalidRedirectCode   = errors.New("invalid redirect status code")
@@ -81,6 +118,10 @@ var (
 	methodNotAllowedHandler = func(c *Context) error {
 		return NewHTTPError(fasthttp.StatusMethodNotAllowed)
 	}
+
+	optionsHandler = func(c *Context) error {
+		return c.NoContent(fasthttp.StatusNoContent)
+	}
 )
 
 func New() (g *Goka) {
@@ -89,18 +130,48 @@ func New() (g *Goka) {
 		return NewContext(nil, g)
 	}
 	g.router = NewRouter(g)
+	g.pre = g.dispatch
+	g.notFoundHandler = notFoundHandler
+	g.methodNotAllowedHandler = methodNotAllowedHandler
 
 	g.defaultHTTPErrorHandler = func(err error, c *Context) {
+		// Drop whatever the failed handler wrote, including headers such as
+		// Set-Cookie, but keep the Allow header of 405 responses.
+		resp := &c.RequestCtx().Response
+		allow := string(resp.Header.Peek(Allow))
+		skipBody := resp.SkipBody
+		resp.Reset()
+		resp.SkipBody = skipBody
+		if allow != "" {
+			resp.Header.Set(Allow, allow)
+		}
+
 		code := fasthttp.StatusInternalServerError
 		msg := fasthttp.StatusMessage(code)
-		if he, ok := err.(*HTTPError); ok {
-			code = he.code
-			msg = he.message
+		switch e := err.(type) {
+		case *HTTPError:
+			code = e.code
+			msg = e.message
+			for k, v := range e.header {
+				resp.Header.Set(k, v)
+			}
+		case *BindingError:
+			code = fasthttp.StatusBadRequest
+			msg = e.Error()
+		case ValidationErrors:
+			code = fasthttp.StatusUnprocessableEntity
+			c.JSON(code, map[string]interface{}{
+				"message": fasthttp.StatusMessage(code),
+				"errors":  e,
+			})
+			return
 		}
 		if g.debug {
 			msg = err.Error()
 		}
-		c.RequestCtx().Error(msg, code)
+		resp.SetStatusCode(code)
+		resp.Header.SetContentType(TextPlainCharsetUTF8)
+		resp.SetBodyString(msg)
 		return
 	}
 	g.SetHTTPErrorHandler(g.defaultHTTPErrorHandler)
@@ -120,6 +191,14 @@ func (g *Goka) SetHTTPErrorHandler(h HTTPErrorHandler) {
 	g.httpErrorHandler = h
 }
 
+func (g *Goka) SetNotFoundHandler(h Handler) {
+	g.notFoundHandler = wrapHandler(h)
+}
+
+func (g *Goka) SetMethodNotAllowedHandler(h Handler) {
+	g.methodNotAllowedHandler = wrapHandler(h)
+}
+
 func (g *Goka) SetRenderer(r Renderer) {
 	g.renderer = r
 }
@@ -132,150 +211,222 @@ func (g *Goka) Debug() bool {
 	return g.debug
 }
 
+// SetAutoHead controls whether HEAD requests are answered by the GET
+// handler of a route that has no HEAD handler. It is enabled by default.
+func (g *Goka) SetAutoHead(auto bool) {
+	g.router.autoHead = auto
+}
+
+// SetAutoOptions controls whether OPTIONS requests to a route without an
+// OPTIONS handler are answered with the allowed methods. It is enabled by
+// default.
+func (g *Goka) SetAutoOptions(auto bool) {
+	g.router.autoOptions = auto
+}
+
+func (g *Goka) SetTrailingSlash(p TrailingSlashPolicy) {
+	g.router.trailingSlash = p
+}
+
+// SetCleanPath enables removal of duplicate slashes and dot segments from
+// request paths before routing.
+func (g *Goka) SetCleanPath(clean bool) {
+	g.router.cleanPath = clean
+}
+
+func (g *Goka) SetCaseInsensitive(ci bool) {
+	g.router.caseInsensitive = ci
+}
+
+// SetRedirectFixedPath makes requests whose path was cleaned or matched
+// case-insensitively redirect to the canonical path instead of being
+// served directly.
+func (g *Goka) SetRedirectFixedPath(redirect bool) {
+	g.router.redirectFixedPath = redirect
+}
+
+// Pre adds middleware that runs before the router looks up the route, so
+// it may change the request method or path used for routing. Errors it
+// returns are handled by the Goka error handler.
+func (g *Goka) Pre(m ...Middleware) {
+	for _, h := range m {
+		g.premiddleware = append(g.premiddleware, wrapMiddleware(h))
+	}
+	g.pre = g.dispatch
+	for i := len(g.premiddleware) - 1; i >= 0; i-- {
+		g.pre = g.premiddleware[i](g.pre)
+	}
+}
+
 func (g *Goka) Use(m ...Middleware) {
 	for _, h := range m {
 		g.middleware = append(g.middleware, wrapMiddleware(h))
 	}
+	g.router.rebuild()
+}
+
+func (g *Goka) Connect(path string, h Handler) *Route {
+	return g.add(nil, CONNECT, path, h)
+}
+
+func (g *Goka) Delete(path string, h Handler) *Route {
+	return g.add(nil, DELETE, path, h)
+}
+
+func (g *Goka) Get(path string, h Handler) *Route {
+	return g.add(nil, GET, path, h)
 }
 
-func (g *Goka) Delete(path string, h Handler) {
-	g.add(DELETE, path, h)
+func (g *Goka) Head(path string, h Handler) *Route {
+	return g.add(nil, HEAD, path, h)
 }
 
-func (g *Goka) Get(path string, h Handler) {
-	g.add(GET, path, h)
+func (g *Goka) Options(path string, h Handler) *Route {
+	return g.add(nil, OPTIONS, path, h)
 }
 
-func (g *Goka) Head(path string, h Handler) {
-	g.add(HEAD, path, h)
+func (g *Goka) Patch(path string, h Handler) *Route {
+	return g.add(nil, PATCH, path, h)
 }
 
-func (g *Goka) Options(path string, h Handler) {
-	g.add(OPTIONS, path, h)
+func (g *Goka) Post(path string, h Handler) *Route {
+	return g.add(nil, POST, path, h)
 }
 
-func (g *Goka) Patch(path string, h Handler) {
-	g.add(PATCH, path, h)
+func (g *Goka) Put(path string, h Handler) *Route {
+	return g.add(nil, PUT, path, h)
 }
 
-func (g *Goka) Post(path string, h Handler) {
-	g.add(POST, path, h)
+func (g *Goka) Trace(path string, h Handler) *Route {
+	return g.add(nil, TRACE, path, h)
 }
 
-func (g *Goka) Put(path string, h Handler) {
-	g.add(PUT, path, h)
+func (g *Goka) Add(method, path string, h Handler) *Route {
+	return g.add(nil, method, path, h)
 }
 
-func (g *Goka) Any(path string, h Handler) {
-	for _, m := range methods {
-		g.add(m, path, h)
+func (g *Goka) Any(path string, h Handler) []*Route {
+	routes := make([]*Route, len(methods))
+	for i, m := range methods {
+		routes[i] = g.add(nil, m, path, h)
 	}
+	return routes
 }
 
-func (g *Goka) Match(methods []string, path string, h Handler) {
-	for _, m := range methods {
-		g.add(m, path, h)
+func (g *Goka) Match(methods []string, path string, h Handler) []*Route {
+	routes := make([]*Route, len(methods))
+	for i, m := range methods {
+		routes[i] = g.add(nil, m, path, h)
 	}
+	return routes
 }
 
-func (g *Goka) add(method, path string, h Handler) {
-	path = g.prefix + path
-	g.router.Add(method, path, wrapHandler(h), g)
-	r := Route{
+func (g *Goka) add(grp *Group, method, path string, h Handler) *Route {
+	hf := wrapHandler(h)
+	r := &Route{
 		Method:  method,
 		Path:    path,
 		Handler: runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name(),
+		router:  g.router,
+		node:    g.router.add(method, path, hf, grp),
+		group:   grp,
+		handler: hf,
 	}
+	r.node.addRoute(r)
 	g.router.routes = append(g.router.routes, r)
+	return r.update()
 }
 
-func (g *Goka) Index(file string) {
-	g.ServeFile("/", file)
+func (g *Goka) Index(file string) *Route {
+	return g.ServeFile("/", file)
 }
 
-func (g *Goka) Favicon(file string) {
-	g.ServeFile("/favicon.ico", file)
+func (g *Goka) Favicon(file string) *Route {
+	return g.ServeFile("/favicon.ico", file)
 }
 
-func (g *Goka) ServeFile(path, file string) {
-	g.Get(path, func(c *Context) {
-		fasthttp.ServeFile(c.RequestCtx(), file)
-	})
+func (g *Goka) ServeFile(path, file string) *Route {
+	return g.Get(path, serveFileHandler(file))
 }
 
 func (g *Goka) Group(prefix string, m ...Middleware) *Group {
-	group := &Group{*g}
-	group.goka.prefix += prefix
-	if len(m) == 0 {
-		mw := make([]MiddlewareFunc, len(group.goka.middleware))
-		copy(mw, group.goka.middleware)
-		group.goka.middleware = mw
-	} else {
-		group.goka.middleware = nil
-		group.Use(m...)
-	}
-	return group
+	return g.newGroup(nil, prefix, m)
+}
+
+func (g *Goka) newGroup(parent *Group, prefix string, m []Middleware) *Group {
+	grp := &Group{prefix: prefix, parent: parent, goka: g}
+	grp.Use(m...)
+	g.router.groups = append(g.router.groups, grp)
+	return grp
 }
 
+// URI returns the URI of the first route registered with handler h. Params
+// that are missing are left as written in the route path and extra ones are
+// ignored. Use Reverse with named routes when several routes share a
+// handler or to get these errors.
 func (g *Goka) URI(h Handler, params ...interface{}) string {
-	uri := new(bytes.Buffer)
-	pl := len(params)
-	n := 0
 	hn := runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name()
 	for _, r := range g.router.routes {
 		if r.Handler == hn {
-			for i, l := 0, len(r.Path); i < l; i++ {
-				if r.Path[i] == ':' && n < pl {
-					for ; i < l && r.Path[i] != '/'; i++ {
-					}
-					uri.WriteString(fmt.Sprintf("%v", params[n]))
-					n++
-				}
-				if i < l {
-					uri.WriteByte(r.Path[i])
-				}
-			}
-			break
+			uri, _ := r.reverse(params, false)
+			return uri
 		}
 	}
-	return uri.String()
+	return ""
 }
 
 func (g *Goka) URL(h Handler, params ...interface{}) string {
 	return g.URI(h, params...)
 }
 
-func (g *Goka) Routes() []Route {
+func (g *Goka) Routes() []*Route {
 	return g.router.routes
 }
 
 func (g *Goka) Serve(rCtx *fasthttp.RequestCtx) {
 
 	c := g.pool.Get().(*Context)
-	h, g := g.router.Find(string(rCtx.Method()), string(rCtx.Path()), c)
 	c.reset(rCtx, g)
-
-	for i := len(g.middleware) - 1; i >= 0; i-- {
-		h = g.middleware[i](h)
+	if err := g.pre(c); err != nil {
+		c.Error(err)
 	}
 
-	if err := h(c); err != nil {
-		g.httpErrorHandler(err, c)
+	if !c.abandoned {
+		g.pool.Put(c)
 	}
+}
 
-	g.pool.Put(c)
+// dispatch routes c and runs the matched handler.
+func (g *Goka) dispatch(c *Context) error {
+	rCtx := c.requestCtx
+	h, grp := g.router.Find(string(rCtx.Method()), b2s(rCtx.Path()), c)
+	c.group = grp
+	if rCtx.IsHead() {
+		rCtx.Response.SkipBody = true
+	}
+	return h(c)
 }
 
-func (g *Goka) Run(addr string) {
-	fasthttp.ListenAndServe(addr, func(rCtx *fasthttp.RequestCtx) {
-		g.Serve(rCtx)
-	})
+// chain wraps h in the middleware of grp and its parents and then in the
+// global middleware.
+func (g *Goka) chain(grp *Group, h HandlerFunc) HandlerFunc {
+	for ; grp != nil; grp = grp.parent {
+		for i := len(grp.middleware) - 1; i >= 0; i-- {
+			h = grp.middleware[i](h)
+		}
+	}
+	for i := len(g.middleware) - 1; i >= 0; i-- {
+		h = g.middleware[i](h)
+	}
+	return h
 }
 
-func (g *Goka) RunTLS(addr, certFile, keyFile string) {
-	fasthttp.ListenAndServeTLS(addr, certFile, keyFile, func(rCtx *fasthttp.RequestCtx) {
-		g.Serve(rCtx)
-	})
+// errorHandler returns the error handler for routes in grp.
+func (g *Goka) errorHandler(grp *Group) HTTPErrorHandler {
+	if h := grp.errorHandler(); h != nil {
+		return h
+	}
+	return g.httpErrorHandler
 }
 
 func wrapMiddleware(m Middleware) MiddlewareFunc {
@@ -304,6 +455,18 @@ func wrapHandlerFuncMW(m HandlerFunc) MiddlewareFunc {
 	}
 }
 
+// b2s converts b to a string without copying it.
+func b2s(b []byte) string {
+	return unsafe.String(unsafe.SliceData(b), len(b))
+}
+
+func serveFileHandler(file string) HandlerFunc {
+	return func(c *Context) error {
+		fasthttp.ServeFile(c.RequestCtx(), file)
+		return nil
+	}
+}
+
 func wrapHandler(h Handler) HandlerFunc {
 	switch h := h.(type) {
 	case HandlerFunc:
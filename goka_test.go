@@ -1,8 +1,198 @@
 package goka
 
 import (
+	"strings"
 	"testing"
+
+	"github.com/valyala/fasthttp"
 )
 
+func request(g *Goka, method, path string) *fasthttp.RequestCtx {
+	rCtx := new(fasthttp.RequestCtx)
+	rCtx.Request.Header.SetMethod(method)
+	rCtx.Request.SetRequestURI(path)
+	g.Serve(rCtx)
+	return rCtx
+}
+
 func TestGokaHandler(t *testing.T) {
+	g := New()
+	g.Get("/hi", func(c *Context) error {
+		return c.String(fasthttp.StatusOK, "hi")
+	})
+	g.Get("/fasthttp", func(rCtx *fasthttp.RequestCtx) {
+		rCtx.SetBodyString("fasthttp")
+	})
+
+	rCtx := request(g, GET, "/hi")
+	if rCtx.Response.StatusCode() != fasthttp.StatusOK || string(rCtx.Response.Body()) != "hi" {
+		t.Errorf("unexpected response %d %q", rCtx.Response.StatusCode(), rCtx.Response.Body())
+	}
+	rCtx = request(g, GET, "/fasthttp")
+	if string(rCtx.Response.Body()) != "fasthttp" {
+		t.Errorf("unexpected body %q", rCtx.Response.Body())
+	}
+	rCtx = request(g, POST, "/hi")
+	if rCtx.Response.StatusCode() != fasthttp.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", rCtx.Response.StatusCode())
+	}
+}
+
+func benchmarkServe(b *testing.B, path string) {
+	g := New()
+	g.Use(func(c *Context) error { return nil })
+	api := g.Group("/api", func(c *Context) error { return nil })
+	api.Get("/users", func(c *Context) error { return nil })
+	api.Get("/users/:id", func(c *Context) error {
+		c.ParamByName("id")
+		return nil
+	})
+
+	rCtx := new(fasthttp.RequestCtx)
+	rCtx.Request.Header.SetMethod(GET)
+	rCtx.Request.SetRequestURI(path)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.Serve(rCtx)
+	}
+}
+
+func BenchmarkServeStatic(b *testing.B) {
+	benchmarkServe(b, "/api/users")
+}
+
+func BenchmarkServeParam(b *testing.B) {
+	benchmarkServe(b, "/api/users/1")
+}
+
+func TestGokaUseAfterRoutes(t *testing.T) {
+	g := New()
+	g.Get("/", func(c *Context) error {
+		return c.String(fasthttp.StatusOK, c.Get("mw").(string))
+	})
+	g.Use(func(c *Context) error {
+		c.Set("mw", "global")
+		return nil
+	})
+	if body := string(request(g, GET, "/").Response.Body()); body != "global" {
+		t.Errorf("expected middleware added after route to run, got %q", body)
+	}
+}
+
+func TestServeAllocs(t *testing.T) {
+	g := New()
+	g.Use(func(c *Context) error { return nil })
+	g.Get("/users", func(c *Context) error { return nil })
+	g.Get("/users/:id", func(c *Context) error { return nil })
+	// Param values are copied once so that they outlive the request.
+	for path, allocs := range map[string]float64{"/users": 0, "/users/1": 1} {
+		rCtx := new(fasthttp.RequestCtx)
+		rCtx.Request.Header.SetMethod(GET)
+		rCtx.Request.SetRequestURI(path)
+		if n := testing.AllocsPerRun(100, func() { g.Serve(rCtx) }); n != allocs {
+			t.Errorf("%s: expected %v allocations, got %v", path, allocs, n)
+		}
+	}
+}
+
+func TestGokaPre(t *testing.T) {
+	g := New()
+	g.Pre(func(c *Context) error {
+		c.RequestCtx().URI().SetPath("/rewritten")
+		return nil
+	})
+	g.Get("/rewritten", func(c *Context) error {
+		return c.String(fasthttp.StatusOK, c.Path())
+	})
+	if body := string(request(g, GET, "/original").Response.Body()); body != "/rewritten" {
+		t.Errorf("expected pre middleware to change routing, got %q", body)
+	}
+
+	g.Pre(func(c *Context) error {
+		return NewHTTPError(fasthttp.StatusForbidden)
+	})
+	if code := request(g, GET, "/original").Response.StatusCode(); code != fasthttp.StatusForbidden {
+		t.Errorf("expected pre middleware error to be handled, got %d", code)
+	}
+}
+
+func TestHTTPErrorHeader(t *testing.T) {
+	g := New()
+	g.Get("/", func(c *Context) error {
+		return NewHTTPError(fasthttp.StatusUnauthorized).SetHeader(WWWAuthenticate, `Basic realm="test"`)
+	})
+	rCtx := request(g, GET, "/")
+	if rCtx.Response.StatusCode() != fasthttp.StatusUnauthorized ||
+		string(rCtx.Response.Header.Peek(WWWAuthenticate)) != `Basic realm="test"` {
+		t.Errorf("expected error header in response, got %d %q", rCtx.Response.StatusCode(), rCtx.Response.Header.Peek(WWWAuthenticate))
+	}
+}
+
+func TestHTTPErrorSetHeaderCopies(t *testing.T) {
+	e := ErrUnsupportedMediaType.SetHeader(AcceptEncoding, "gzip")
+	if e == ErrUnsupportedMediaType || ErrUnsupportedMediaType.Header() != nil {
+		t.Error("expected SetHeader to leave the shared error unchanged")
+	}
+	if e.Code() != fasthttp.StatusUnsupportedMediaType || e.Header()[AcceptEncoding] != "gzip" {
+		t.Errorf("unexpected error %d %v", e.Code(), e.Header())
+	}
+}
+
+func TestDefaultHTTPErrorHandlerResetsResponse(t *testing.T) {
+	g := New()
+	g.Get("/download", func(c *Context) error {
+		c.RequestCtx().Response.Header.Set(ContentDisposition, "attachment")
+		c.RequestCtx().Response.Header.Set("Set-Cookie", "session=1")
+		c.RequestCtx().SetBodyString("partial")
+		return NewHTTPError(fasthttp.StatusInternalServerError)
+	})
+
+	rCtx := request(g, GET, "/download")
+	resp := &rCtx.Response
+	if len(resp.Header.Peek(ContentDisposition)) != 0 || len(resp.Header.Peek("Set-Cookie")) != 0 ||
+		string(resp.Body()) != "Internal Server Error" {
+		t.Errorf("expected a clean error response, got %q", resp.String())
+	}
+
+	rCtx = request(g, POST, "/download")
+	if allow := string(rCtx.Response.Header.Peek(Allow)); allow != "GET, HEAD, OPTIONS" {
+		t.Errorf("expected Allow to be kept on 405, got %q", allow)
+	}
+}
+
+func TestGokaPreSeesNoStaleRoute(t *testing.T) {
+	g := New()
+	var path, id string
+	var route *Route
+	g.Pre(func(c *Context) error {
+		path, id, route = c.Path(), c.ParamByName("id"), c.Route()
+		return nil
+	})
+	g.Get("/users/:id", func(c *Context) error { return nil })
+
+	request(g, GET, "/users/42")
+	request(g, GET, "/other")
+	if path != "" || id != "" || route != nil {
+		t.Errorf("expected no route state before routing, got %q %q %v", path, id, route)
+	}
+}
+
+func TestGokaParamsOutliveRequest(t *testing.T) {
+	g := New()
+	var ids []string
+	g.Get("/users/:id", func(c *Context) error {
+		ids = append(ids, c.ParamByName("id"))
+		return nil
+	})
+
+	rCtx := new(fasthttp.RequestCtx)
+	for _, path := range []string{"/users/alice", "/users/bobby"} {
+		// Reusing the request overwrites its path buffer.
+		rCtx.Request.SetRequestURI(path)
+		g.Serve(rCtx)
+	}
+	if strings.Join(ids, ",") != "alice,bobby" {
+		t.Errorf("expected param values to be kept, got %v", ids)
+	}
 }
@@ -0,0 +1,97 @@
+package gokatest
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net"
+	"net/http"
+
+	"github.com/gotokatsuya/goka"
+	"github.com/valyala/fasthttp"
+)
+
+type Recorder struct {
+	Code   int
+	Header http.Header
+	Body   []byte
+
+	// Err is the error decoding a compressed body, in which case Body
+	// holds the body as sent.
+	Err error
+}
+
+var remoteAddr = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1234}
+
+// NewRequest returns a request context for method and target that can be
+// passed to Serve. Headers may be set on its Request before serving.
+func NewRequest(method, target string, body []byte) *fasthttp.RequestCtx {
+	req := new(fasthttp.Request)
+	req.Header.SetMethod(method)
+	req.SetRequestURI(target)
+	if body != nil {
+		req.SetBody(body)
+	}
+	rCtx := new(fasthttp.RequestCtx)
+	rCtx.Init(req, remoteAddr, nil)
+	return rCtx
+}
+
+// Serve runs rCtx through g and records the response. Responses of routes
+// with Route.Timeout are recorded too, including the 503 sent when the
+// handler times out.
+func Serve(g *goka.Goka, rCtx *fasthttp.RequestCtx) *Recorder {
+	g.Serve(rCtx)
+
+	resp := &rCtx.Response
+	rec := &Recorder{
+		Code:   resp.StatusCode(),
+		Header: make(http.Header),
+	}
+	resp.Header.VisitAll(func(k, v []byte) {
+		rec.Header.Add(string(k), string(v))
+	})
+
+	var err error
+	switch string(resp.Header.Peek(goka.ContentEncoding)) {
+	case "":
+		if !resp.SkipBody {
+			rec.Body = append([]byte(nil), resp.Body()...)
+		}
+	case "gzip":
+		rec.Body, err = resp.BodyGunzip()
+	case "deflate":
+		rec.Body, err = resp.BodyInflate()
+	case "br":
+		rec.Body, err = resp.BodyUnbrotli()
+	case "zstd":
+		rec.Body, err = resp.BodyUnzstd()
+	default:
+		rec.Body = append([]byte(nil), resp.Body()...)
+	}
+	if err != nil {
+		rec.Body = append([]byte(nil), resp.Body()...)
+		rec.Err = err
+	}
+	return rec
+}
+
+// Do builds a request with NewRequest, sets header and serves it through g.
+func Do(g *goka.Goka, method, target string, body []byte, header map[string]string) *Recorder {
+	rCtx := NewRequest(method, target, body)
+	for k, v := range header {
+		rCtx.Request.Header.Set(k, v)
+	}
+	return Serve(g, rCtx)
+}
+
+func (r *Recorder) BodyString() string {
+	return string(r.Body)
+}
+
+func (r *Recorder) JSON(i interface{}) error {
+	return json.Unmarshal(r.Body, i)
+}
+
+func (r *Recorder) XML(i interface{}) error {
+	return xml.Unmarshal(r.Body, i)
+}
@@ -0,0 +1,82 @@
+package gokatest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gotokatsuya/goka"
+	"github.com/valyala/fasthttp"
+)
+
+func TestServe(t *testing.T) {
+	g := goka.New()
+	g.Use(func(c *goka.Context) error {
+		c.RequestCtx().Response.Header.Set("X-Middleware", "1")
+		return nil
+	})
+	g.Get("/users/:id", func(c *goka.Context) error {
+		return c.JSON(fasthttp.StatusOK, map[string]string{
+			"id":     c.ParamByName("id"),
+			"tenant": string(c.RequestCtx().Request.Header.Peek("X-Tenant")),
+		})
+	})
+	g.Post("/fail", func(c *goka.Context) error {
+		return errors.New("boom")
+	})
+
+	rec := Do(g, goka.GET, "/users/1", nil, map[string]string{"X-Tenant": "acme"})
+	if rec.Code != fasthttp.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if rec.Header.Get("X-Middleware") != "1" {
+		t.Error("middleware header missing")
+	}
+	var body map[string]string
+	if err := rec.JSON(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body["id"] != "1" || body["tenant"] != "acme" {
+		t.Errorf("unexpected body %v", body)
+	}
+
+	rec = Serve(g, NewRequest(goka.POST, "/fail", []byte("x")))
+	if rec.Code != fasthttp.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", rec.Code)
+	}
+
+	rec = Serve(g, NewRequest(goka.GET, "/missing", nil))
+	if rec.Code != fasthttp.StatusNotFound {
+		t.Errorf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestServeBadEncoding(t *testing.T) {
+	g := goka.New()
+	g.Get("/", func(c *goka.Context) error {
+		c.RequestCtx().Response.Header.Set(goka.ContentEncoding, "gzip")
+		return c.String(fasthttp.StatusOK, "not gzip")
+	})
+	rec := Do(g, goka.GET, "/", nil, nil)
+	if rec.Err == nil || rec.BodyString() != "not gzip" {
+		t.Errorf("expected a decoding error and the raw body, got %v %q", rec.Err, rec.Body)
+	}
+}
+
+func TestServeTimeout(t *testing.T) {
+	g := goka.New()
+	g.Get("/slow", func(c *goka.Context) error {
+		<-c.Done()
+		return nil
+	}).Timeout(5 * time.Millisecond)
+	g.Get("/fast", func(c *goka.Context) error {
+		return c.String(fasthttp.StatusOK, "fast")
+	}).Timeout(time.Second)
+
+	if rec := Do(g, goka.GET, "/slow", nil, nil); rec.Code != fasthttp.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", rec.Code)
+	}
+	if rec := Do(g, goka.GET, "/fast", nil, nil); rec.Code != fasthttp.StatusOK || rec.BodyString() != "fast" {
+		t.Errorf("expected the handler's response, got %d %q", rec.Code, rec.Body)
+	}
+}
@@ -1,59 +1,126 @@
 package goka
 
+// Group is a set of routes sharing a path prefix and middleware. It shares
+// the router and configuration of the Goka it was created from, and may
+// override the error, 404 and 405 handlers for its routes.
 type Group struct {
-	goka Goka
+	prefix                  string
+	middleware              []MiddlewareFunc
+	parent                  *Group
+	goka                    *Goka
+	httpErrorHandler        HTTPErrorHandler
+	notFoundHandler         HandlerFunc
+	methodNotAllowedHandler HandlerFunc
 }
 
 func (g *Group) Use(m ...Middleware) {
 	for _, h := range m {
-		g.goka.middleware = append(g.goka.middleware, wrapMiddleware(h))
+		g.middleware = append(g.middleware, wrapMiddleware(h))
 	}
+	g.goka.router.rebuild()
 }
 
-func (g *Group) Delete(path string, h Handler) {
-	g.goka.Delete(path, h)
+func (g *Group) SetHTTPErrorHandler(h HTTPErrorHandler) {
+	g.httpErrorHandler = h
 }
 
-func (g *Group) Get(path string, h Handler) {
-	g.goka.Get(path, h)
+func (g *Group) SetNotFoundHandler(h Handler) {
+	g.notFoundHandler = wrapHandler(h)
 }
 
-func (g *Group) Head(path string, h Handler) {
-	g.goka.Head(path, h)
+func (g *Group) SetMethodNotAllowedHandler(h Handler) {
+	g.methodNotAllowedHandler = wrapHandler(h)
 }
 
-func (g *Group) Options(path string, h Handler) {
-	g.goka.Options(path, h)
+func (g *Group) Connect(path string, h Handler) *Route {
+	return g.Add(CONNECT, path, h)
 }
 
-func (g *Group) Patch(path string, h Handler) {
-	g.goka.Patch(path, h)
+func (g *Group) Delete(path string, h Handler) *Route {
+	return g.Add(DELETE, path, h)
 }
 
-func (g *Group) Post(path string, h Handler) {
-	g.goka.Post(path, h)
+func (g *Group) Get(path string, h Handler) *Route {
+	return g.Add(GET, path, h)
 }
 
-func (g *Group) Put(path string, h Handler) {
-	g.goka.Put(path, h)
+func (g *Group) Head(path string, h Handler) *Route {
+	return g.Add(HEAD, path, h)
 }
 
-func (g *Group) Any(path string, h Handler) {
-	for _, m := range methods {
-		g.goka.add(m, path, h)
+func (g *Group) Options(path string, h Handler) *Route {
+	return g.Add(OPTIONS, path, h)
+}
+
+func (g *Group) Patch(path string, h Handler) *Route {
+	return g.Add(PATCH, path, h)
+}
+
+func (g *Group) Post(path string, h Handler) *Route {
+	return g.Add(POST, path, h)
+}
+
+func (g *Group) Put(path string, h Handler) *Route {
+	return g.Add(PUT, path, h)
+}
+
+func (g *Group) Trace(path string, h Handler) *Route {
+	return g.Add(TRACE, path, h)
+}
+
+func (g *Group) Add(method, path string, h Handler) *Route {
+	return g.goka.add(g, method, g.prefix+path, h)
+}
+
+func (g *Group) Any(path string, h Handler) []*Route {
+	routes := make([]*Route, len(methods))
+	for i, m := range methods {
+		routes[i] = g.Add(m, path, h)
 	}
+	return routes
 }
 
-func (g *Group) Match(methods []string, path string, h Handler) {
-	for _, m := range methods {
-		g.goka.add(m, path, h)
+func (g *Group) Match(methods []string, path string, h Handler) []*Route {
+	routes := make([]*Route, len(methods))
+	for i, m := range methods {
+		routes[i] = g.Add(m, path, h)
 	}
+	return routes
 }
 
-func (g *Group) ServeFile(path, file string) {
-	g.goka.ServeFile(path, file)
+func (g *Group) ServeFile(path, file string) *Route {
+	return g.Get(path, serveFileHandler(file))
 }
 
 func (g *Group) Group(prefix string, m ...Middleware) *Group {
-	return g.goka.Group(prefix, m...)
+	return g.goka.newGroup(g, g.prefix+prefix, m)
+}
+
+// errorHandler returns the error handler of the nearest group that has
+// one, or the Goka error handler.
+func (g *Group) errorHandler() HTTPErrorHandler {
+	for ; g != nil; g = g.parent {
+		if g.httpErrorHandler != nil {
+			return g.httpErrorHandler
+		}
+	}
+	return nil
+}
+
+func (g *Group) notFound() HandlerFunc {
+	for ; g != nil; g = g.parent {
+		if g.notFoundHandler != nil {
+			return g.notFoundHandler
+		}
+	}
+	return nil
+}
+
+func (g *Group) methodNotAllowed() HandlerFunc {
+	for ; g != nil; g = g.parent {
+		if g.methodNotAllowedHandler != nil {
+			return g.methodNotAllowedHandler
+		}
+	}
+	return nil
 }
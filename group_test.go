@@ -0,0 +1,134 @@
+package goka
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestGroup(t *testing.T) {
+	g := New()
+	var trace []string
+	mw := func(name string) HandlerFunc {
+		return func(c *Context) error {
+			trace = append(trace, name)
+			return nil
+		}
+	}
+	g.Use(mw("global"))
+
+	api := g.Group("/api", mw("api"))
+	v1 := api.Group("/v1")
+	v1.Get("/users", func(c *Context) error {
+		trace = append(trace, "handler")
+		return errors.New("boom")
+	})
+	// Middleware added after routes still applies.
+	v1.Use(mw("v1"))
+	v1.SetNotFoundHandler(func(c *Context) error {
+		return c.String(fasthttp.StatusNotFound, "v1 not found")
+	})
+
+	// Configuration changed after grouping is shared.
+	g.SetHTTPErrorHandler(func(err error, c *Context) {
+		c.String(fasthttp.StatusTeapot, err.Error())
+	})
+
+	rCtx := request(g, GET, "/api/v1/users")
+	if strings.Join(trace, ",") != "global,api,v1,handler" {
+		t.Errorf("unexpected middleware order %v", trace)
+	}
+	if rCtx.Response.StatusCode() != fasthttp.StatusTeapot {
+		t.Errorf("expected shared error handler, got %d", rCtx.Response.StatusCode())
+	}
+
+	api.SetHTTPErrorHandler(func(err error, c *Context) {
+		c.String(fasthttp.StatusBadGateway, "api: "+err.Error())
+	})
+	rCtx = request(g, GET, "/api/v1/users")
+	if rCtx.Response.StatusCode() != fasthttp.StatusBadGateway || string(rCtx.Response.Body()) != "api: boom" {
+		t.Errorf("expected group error handler, got %d %q", rCtx.Response.StatusCode(), rCtx.Response.Body())
+	}
+
+	rCtx = request(g, GET, "/api/v1/missing")
+	if string(rCtx.Response.Body()) != "v1 not found" {
+		t.Errorf("expected group 404 handler, got %q", rCtx.Response.Body())
+	}
+	rCtx = request(g, GET, "/missing")
+	if rCtx.Response.StatusCode() != fasthttp.StatusTeapot {
+		t.Errorf("expected default 404 through error handler, got %d", rCtx.Response.StatusCode())
+	}
+
+	api.SetMethodNotAllowedHandler(func(c *Context) error {
+		return c.String(fasthttp.StatusMethodNotAllowed, "api 405")
+	})
+	rCtx = request(g, POST, "/api/v1/users")
+	if string(rCtx.Response.Body()) != "api 405" {
+		t.Errorf("expected group 405 handler, got %q", rCtx.Response.Body())
+	}
+}
+
+func TestGroupPrefixBoundary(t *testing.T) {
+	g := New()
+	api := g.Group("/api")
+	api.SetNotFoundHandler(func(c *Context) error {
+		return c.String(fasthttp.StatusNotFound, "api404")
+	})
+	for path, want := range map[string]string{
+		"/api":         "api404",
+		"/api/missing": "api404",
+		"/apiary":      "Not Found",
+	} {
+		if body := string(request(g, GET, path).Response.Body()); body != want {
+			t.Errorf("%s: expected %q, got %q", path, want, body)
+		}
+	}
+}
+
+func TestGroupNotLeakedAcrossRequests(t *testing.T) {
+	g := New()
+	g.Pre(func(c *Context) error {
+		if string(c.RequestCtx().Path()) == "/denied" {
+			return NewHTTPError(fasthttp.StatusForbidden)
+		}
+		return nil
+	})
+	api := g.Group("/api")
+	api.SetHTTPErrorHandler(func(err error, c *Context) {
+		c.String(fasthttp.StatusBadGateway, "api")
+	})
+	api.Get("/fail", func(c *Context) error {
+		return errors.New("fail")
+	})
+
+	request(g, GET, "/api/fail")
+	if code := request(g, GET, "/denied").Response.StatusCode(); code != fasthttp.StatusForbidden {
+		t.Errorf("expected Goka error handler for pre-routing error, got %d", code)
+	}
+}
+
+func TestGroupSharedPath(t *testing.T) {
+	g := New()
+	api := g.Group("/api")
+	api.SetHTTPErrorHandler(func(err error, c *Context) {
+		c.String(fasthttp.StatusTeapot, "api")
+	})
+	api.Get("/users", func(c *Context) error {
+		return errors.New("fail")
+	})
+	g.Post("/api/users", func(c *Context) error {
+		return errors.New("fail")
+	})
+
+	for method, code := range map[string]int{
+		GET:  fasthttp.StatusTeapot,
+		HEAD: fasthttp.StatusTeapot,
+		POST: fasthttp.StatusInternalServerError,
+	} {
+		if got := request(g, method, "/api/users").Response.StatusCode(); got != code {
+			t.Errorf("%s: expected %d, got %d", method, code, got)
+		}
+	}
+}
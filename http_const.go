@@ -5,6 +5,7 @@ const (
 	// Methods
 	//-------------
 
+	CONNECT = "CONNECT"
 	DELETE  = "DELETE"
 	GET     = "GET"
 	HEAD    = "HEAD"
@@ -12,6 +13,7 @@ const (
 	PATCH   = "PATCH"
 	POST    = "POST"
 	PUT     = "PUT"
+	TRACE   = "TRACE"
 
 	//-------------
 	// Media types
@@ -42,16 +44,28 @@ const (
 	// Headers
 	//---------
 
-	AcceptEncoding     = "Accept-Encoding"
-	Authorization      = "Authorization"
-	ContentDisposition = "Content-Disposition"
-	ContentEncoding    = "Content-Encoding"
-	ContentLength      = "Content-Length"
-	ContentType        = "Content-Type"
-	Location           = "Location"
-	Upgrade            = "Upgrade"
-	Vary               = "Vary"
-	WWWAuthenticate    = "WWW-Authenticate"
-	XForwardedFor      = "X-Forwarded-For"
-	XRealIP            = "X-Real-IP"
+	AcceptEncoding                = "Accept-Encoding"
+	AccessControlAllowCredentials = "Access-Control-Allow-Credentials"
+	AccessControlAllowHeaders     = "Access-Control-Allow-Headers"
+	AccessControlAllowMethods     = "Access-Control-Allow-Methods"
+	AccessControlAllowOrigin      = "Access-Control-Allow-Origin"
+	AccessControlExposeHeaders    = "Access-Control-Expose-Headers"
+	AccessControlMaxAge           = "Access-Control-Max-Age"
+	AccessControlRequestHeaders   = "Access-Control-Request-Headers"
+	AccessControlRequestMethod    = "Access-Control-Request-Method"
+	Allow                         = "Allow"
+	Authorization                 = "Authorization"
+	ContentDisposition            = "Content-Disposition"
+	ContentEncoding               = "Content-Encoding"
+	ContentLength                 = "Content-Length"
+	ContentType                   = "Content-Type"
+	Location                      = "Location"
+	Origin                        = "Origin"
+	Upgrade                       = "Upgrade"
+	Vary                          = "Vary"
+	WWWAuthenticate               = "WWW-Authenticate"
+	XForwardedFor                 = "X-Forwarded-For"
+	XHTTPMethodOverride           = "X-HTTP-Method-Override"
+	XRealIP                       = "X-Real-IP"
+	XRequestID                    = "X-Request-ID"
 )
@@ -24,3 +24,21 @@ func (e *HTTPError) Code() int {
 func (e *HTTPError) Error() string {
 	return e.message
 }
+
+// SetHeader returns a copy of e with a header, such as WWW-Authenticate,
+// that the default error handler sends with the error response. e itself
+// is not changed, so shared errors such as ErrUnsupportedMediaType can be
+// used safely.
+func (e *HTTPError) SetHeader(key, value string) *HTTPError {
+	he := *e
+	he.header = make(map[string]string, len(e.header)+1)
+	for k, v := range e.header {
+		he.header[k] = v
+	}
+	he.header[key] = value
+	return &he
+}
+
+func (e *HTTPError) Header() map[string]string {
+	return e.header
+}
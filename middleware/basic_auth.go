@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+
+	"github.com/gotokatsuya/goka"
+	"github.com/valyala/fasthttp"
+)
+
+type (
+	// BasicAuthConfig configures the BasicAuth middleware.
+	BasicAuthConfig struct {
+		Skipper Skipper
+
+		// Validator checks the credentials. It may store the principal with
+		// Context.Set. Required.
+		Validator BasicAuthValidator
+
+		// Realm is sent in the WWW-Authenticate challenge. Defaults to
+		// "Restricted".
+		Realm string
+	}
+
+	// BasicAuthValidator reports whether user and password are valid. It
+	// should compare secrets with crypto/subtle.ConstantTimeCompare.
+	BasicAuthValidator func(user, password string, c *goka.Context) (bool, error)
+)
+
+const defaultRealm = "Restricted"
+
+// BasicAuth requires HTTP Basic credentials accepted by fn and answers
+// other requests with 401 Unauthorized and a Basic challenge.
+func BasicAuth(fn BasicAuthValidator) goka.MiddlewareFunc {
+	return BasicAuthWithConfig(BasicAuthConfig{Validator: fn})
+}
+
+func BasicAuthWithConfig(config BasicAuthConfig) goka.MiddlewareFunc {
+	if config.Validator == nil {
+		panic("goka => basic auth middleware requires a validator")
+	}
+	if config.Skipper == nil {
+		config.Skipper = DefaultSkipper
+	}
+	if config.Realm == "" {
+		config.Realm = defaultRealm
+	}
+	challenge := "Basic realm=" + quote(config.Realm) + `, charset="UTF-8"`
+
+	return func(next goka.HandlerFunc) goka.HandlerFunc {
+		return func(c *goka.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+			auth := string(c.RequestCtx().Request.Header.Peek(goka.Authorization))
+			if user, password, ok := parseBasicAuth(auth); ok {
+				valid, err := config.Validator(user, password, c)
+				if err != nil {
+					return err
+				}
+				if valid {
+					return next(c)
+				}
+			}
+			return goka.NewHTTPError(fasthttp.StatusUnauthorized).SetHeader(goka.WWWAuthenticate, challenge)
+		}
+	}
+}
+
+func parseBasicAuth(auth string) (user, password string, ok bool) {
+	const prefix = "Basic "
+	if len(auth) < len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return
+	}
+	b, err := base64.StdEncoding.DecodeString(auth[len(prefix):])
+	if err != nil {
+		return
+	}
+	return strings.Cut(string(b), ":")
+}
+
+// BasicAuthAccounts returns a validator that accepts the user names and
+// passwords in accounts, comparing them in constant time, and stores the
+// user name under the "user" key of the Context.
+func BasicAuthAccounts(accounts map[string]string) BasicAuthValidator {
+	return func(user, password string, c *goka.Context) (bool, error) {
+		match := 0
+		for u, p := range accounts {
+			match |= subtle.ConstantTimeCompare([]byte(user), []byte(u)) &
+				subtle.ConstantTimeCompare([]byte(password), []byte(p))
+		}
+		if match == 1 {
+			c.Set("user", user)
+		}
+		return match == 1, nil
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/gotokatsuya/goka"
+	"github.com/gotokatsuya/goka/gokatest"
+	"github.com/valyala/fasthttp"
+)
+
+func principalHandler(c *goka.Context) error {
+	p, _ := c.Get("user").(string)
+	return c.String(fasthttp.StatusOK, p)
+}
+
+func TestBasicAuth(t *testing.T) {
+	g := goka.New()
+	g.Use(BasicAuthWithConfig(BasicAuthConfig{
+		Validator: BasicAuthAccounts(map[string]string{"joe": "secret"}),
+		Realm:     "Admin",
+		Skipper: func(c *goka.Context) bool {
+			return string(c.RequestCtx().Path()) == "/public"
+		},
+	}))
+	g.Get("/", principalHandler)
+	g.Get("/public", principalHandler)
+
+	basic := func(s string) map[string]string {
+		return map[string]string{goka.Authorization: "Basic " + base64.StdEncoding.EncodeToString([]byte(s))}
+	}
+	tests := []struct {
+		path   string
+		header map[string]string
+		code   int
+		body   string
+	}{
+		{"/", basic("joe:secret"), fasthttp.StatusOK, "joe"},
+		{"/", map[string]string{goka.Authorization: "basic am9lOnNlY3JldA=="}, fasthttp.StatusOK, "joe"},
+		{"/", basic("joe:wrong"), fasthttp.StatusUnauthorized, ""},
+		{"/", basic("jo:secret"), fasthttp.StatusUnauthorized, ""},
+		{"/", map[string]string{goka.Authorization: "Basic !!!"}, fasthttp.StatusUnauthorized, ""},
+		{"/", nil, fasthttp.StatusUnauthorized, ""},
+		{"/public", nil, fasthttp.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		rec := gokatest.Do(g, goka.GET, tt.path, nil, tt.header)
+		if rec.Code != tt.code {
+			t.Errorf("%s %v: expected %d, got %d", tt.path, tt.header, tt.code, rec.Code)
+			continue
+		}
+		if tt.code == fasthttp.StatusOK && rec.BodyString() != tt.body {
+			t.Errorf("%s %v: expected principal %q, got %q", tt.path, tt.header, tt.body, rec.BodyString())
+		}
+		if tt.code == fasthttp.StatusUnauthorized {
+			if got := rec.Header.Get(goka.WWWAuthenticate); got != `Basic realm="Admin", charset="UTF-8"` {
+				t.Errorf("%v: unexpected challenge %q", tt.header, got)
+			}
+		}
+	}
+}
+
+func TestBasicAuthValidatorError(t *testing.T) {
+	g := goka.New()
+	g.Use(BasicAuth(func(user, password string, c *goka.Context) (bool, error) {
+		return false, errors.New("store unavailable")
+	}))
+	g.Get("/", principalHandler)
+	rec := gokatest.Do(g, goka.GET, "/", nil, map[string]string{goka.Authorization: "Basic am9lOnNlY3JldA=="})
+	if rec.Code != fasthttp.StatusInternalServerError {
+		t.Errorf("expected validator error to give 500, got %d", rec.Code)
+	}
+}
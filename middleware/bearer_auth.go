@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gotokatsuya/goka"
+	"github.com/valyala/fasthttp"
+)
+
+type (
+	// BearerAuthConfig configures the BearerAuth middleware.
+	BearerAuthConfig struct {
+		Skipper Skipper
+
+		// Validator checks the token. It may store the principal with
+		// Context.Set. Required.
+		Validator BearerAuthValidator
+
+		// Realm is sent in the WWW-Authenticate challenge. Defaults to
+		// "Restricted".
+		Realm string
+	}
+
+	BearerAuthValidator func(token string, c *goka.Context) (bool, error)
+)
+
+// BearerAuth requires a bearer token accepted by fn in the Authorization
+// header and answers other requests with 401 Unauthorized and a Bearer
+// challenge as described in RFC 6750.
+func BearerAuth(fn BearerAuthValidator) goka.MiddlewareFunc {
+	return BearerAuthWithConfig(BearerAuthConfig{Validator: fn})
+}
+
+func BearerAuthWithConfig(config BearerAuthConfig) goka.MiddlewareFunc {
+	if config.Validator == nil {
+		panic("goka => bearer auth middleware requires a validator")
+	}
+	if config.Skipper == nil {
+		config.Skipper = DefaultSkipper
+	}
+	if config.Realm == "" {
+		config.Realm = defaultRealm
+	}
+	challenge := "Bearer realm=" + quote(config.Realm)
+
+	return func(next goka.HandlerFunc) goka.HandlerFunc {
+		return func(c *goka.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+			const prefix = "Bearer "
+			auth := string(c.RequestCtx().Request.Header.Peek(goka.Authorization))
+			if len(auth) < len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+				return goka.NewHTTPError(fasthttp.StatusUnauthorized).SetHeader(goka.WWWAuthenticate, challenge)
+			}
+
+			valid, err := config.Validator(strings.TrimSpace(auth[len(prefix):]), c)
+			if err != nil {
+				return err
+			}
+			if !valid {
+				return goka.NewHTTPError(fasthttp.StatusUnauthorized).
+					SetHeader(goka.WWWAuthenticate, challenge+`, error="invalid_token"`)
+			}
+			return next(c)
+		}
+	}
+}
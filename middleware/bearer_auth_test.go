@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/gotokatsuya/goka"
+	"github.com/gotokatsuya/goka/gokatest"
+	"github.com/valyala/fasthttp"
+)
+
+func TestBearerAuth(t *testing.T) {
+	g := goka.New()
+	g.Use(BearerAuthWithConfig(BearerAuthConfig{
+		Realm: "api",
+		Validator: func(token string, c *goka.Context) (bool, error) {
+			if token == "valid-token" {
+				c.Set("user", "svc")
+				return true, nil
+			}
+			return false, nil
+		},
+	}))
+	g.Get("/", principalHandler)
+
+	tests := []struct {
+		auth      string
+		code      int
+		challenge string
+	}{
+		{"Bearer valid-token", fasthttp.StatusOK, ""},
+		{"bearer valid-token", fasthttp.StatusOK, ""},
+		{"Bearer other", fasthttp.StatusUnauthorized, `Bearer realm="api", error="invalid_token"`},
+		{"Basic dXNlcjpwYXNz", fasthttp.StatusUnauthorized, `Bearer realm="api"`},
+		{"", fasthttp.StatusUnauthorized, `Bearer realm="api"`},
+	}
+	for _, tt := range tests {
+		rec := gokatest.Do(g, goka.GET, "/", nil, map[string]string{goka.Authorization: tt.auth})
+		if rec.Code != tt.code || rec.Header.Get(goka.WWWAuthenticate) != tt.challenge {
+			t.Errorf("%q: expected %d %q, got %d %q", tt.auth, tt.code, tt.challenge, rec.Code, rec.Header.Get(goka.WWWAuthenticate))
+		}
+		if tt.code == fasthttp.StatusOK && rec.BodyString() != "svc" {
+			t.Errorf("%q: expected principal, got %q", tt.auth, rec.BodyString())
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gotokatsuya/goka"
+	"github.com/klauspost/compress/zstd"
+	"github.com/valyala/fasthttp"
+)
+
+type (
+	// CompressConfig configures the Compress middleware.
+	CompressConfig struct {
+		// Encodings lists the supported encodings in order of preference,
+		// used to break ties between equal q-values. Defaults to br, zstd,
+		// gzip and deflate.
+		Encodings []string
+
+		// MinLength is the smallest body, in bytes, that is compressed.
+		// Streamed bodies are always compressed. Defaults to 1024.
+		MinLength int
+
+		// ContentTypes lists the media types that are compressed. An entry
+		// ending in / matches every subtype. Defaults to text/, JSON,
+		// JavaScript, XML and SVG.
+		ContentTypes []string
+	}
+
+	encoder struct {
+		appendBytes func(dst, src []byte) []byte
+		newWriter   func(w io.Writer) io.WriteCloser
+	}
+)
+
+var (
+	DefaultCompressConfig = CompressConfig{
+		Encodings: []string{"br", "zstd", "gzip", "deflate"},
+		MinLength: 1024,
+		ContentTypes: []string{
+			"text/",
+			goka.ApplicationJSON,
+			goka.ApplicationJavaScript,
+			goka.ApplicationXML,
+			"image/svg+xml",
+		},
+	}
+
+	encoders = map[string]encoder{
+		"br": {
+			appendBytes: fasthttp.AppendBrotliBytes,
+			newWriter: func(w io.Writer) io.WriteCloser {
+				return brotli.NewWriterLevel(w, fasthttp.CompressBrotliDefaultCompression)
+			},
+		},
+		"zstd": {
+			appendBytes: fasthttp.AppendZstdBytes,
+			newWriter: func(w io.Writer) io.WriteCloser {
+				zw, _ := zstd.NewWriter(w)
+				return zw
+			},
+		},
+		"gzip": {
+			appendBytes: fasthttp.AppendGzipBytes,
+			newWriter: func(w io.Writer) io.WriteCloser {
+				return gzip.NewWriter(w)
+			},
+		},
+		"deflate": {
+			appendBytes: fasthttp.AppendDeflateBytes,
+			newWriter: func(w io.Writer) io.WriteCloser {
+				return zlib.NewWriter(w)
+			},
+		},
+	}
+)
+
+// Compress compresses response bodies with the encoding negotiated from
+// the Accept-Encoding request header. Bodies that already have a
+// Content-Encoding are left alone, and streamed bodies are only compressed
+// when they were set by Context.Stream.
+func Compress() goka.MiddlewareFunc {
+	return CompressWithConfig(DefaultCompressConfig)
+}
+
+func CompressWithConfig(config CompressConfig) goka.MiddlewareFunc {
+	if len(config.Encodings) == 0 {
+		config.Encodings = DefaultCompressConfig.Encodings
+	}
+	if config.MinLength <= 0 {
+		config.MinLength = DefaultCompressConfig.MinLength
+	}
+	if len(config.ContentTypes) == 0 {
+		config.ContentTypes = DefaultCompressConfig.ContentTypes
+	}
+	for _, e := range config.Encodings {
+		if _, ok := encoders[e]; !ok {
+			panic("goka => unsupported encoding " + e)
+		}
+	}
+
+	return func(next goka.HandlerFunc) goka.HandlerFunc {
+		return func(c *goka.Context) error {
+			resp := &c.RequestCtx().Response
+			addVary(&resp.Header, goka.AcceptEncoding)
+			if err := next(c); err != nil {
+				return err
+			}
+
+			if resp.SkipBody || len(resp.Header.ContentEncoding()) > 0 ||
+				!compressible(config.ContentTypes, string(resp.Header.ContentType())) {
+				return nil
+			}
+			name := negotiateEncoding(string(c.RequestCtx().Request.Header.Peek(goka.AcceptEncoding)), config.Encodings)
+			if name == "" {
+				return nil
+			}
+			enc := encoders[name]
+
+			if resp.IsBodyStream() {
+				if c.TransformBodyStream(func(r io.Reader) io.Reader {
+					return fasthttp.NewStreamReader(func(w *bufio.Writer) {
+						zw := enc.newWriter(w)
+						io.Copy(zw, r)
+						zw.Close()
+					})
+				}) {
+					resp.Header.SetContentEncoding(name)
+				}
+				return nil
+			}
+
+			body := resp.Body()
+			if len(body) < config.MinLength {
+				return nil
+			}
+			resp.SetBody(enc.appendBytes(nil, body))
+			resp.Header.SetContentEncoding(name)
+			return nil
+		}
+	}
+}
+
+func compressible(types []string, ct string) bool {
+	if i := strings.IndexByte(ct, ';'); i >= 0 {
+		ct = ct[:i]
+	}
+	ct = strings.TrimSpace(strings.ToLower(ct))
+	for _, t := range types {
+		if t == ct || strings.HasSuffix(t, "/") && strings.HasPrefix(ct, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateEncoding returns the encoding in supported with the highest
+// q-value in the Accept-Encoding header h, or "" if none is acceptable.
+func negotiateEncoding(h string, supported []string) string {
+	if h == "" {
+		return ""
+	}
+	q := make(map[string]float64)
+	for _, part := range strings.Split(h, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		v := 1.0
+		for _, p := range strings.Split(params, ";") {
+			k, val, ok := strings.Cut(strings.TrimSpace(p), "=")
+			if ok && strings.EqualFold(k, "q") {
+				if f, err := strconv.ParseFloat(val, 64); err == nil {
+					v = f
+				} else {
+					v = 0
+				}
+			}
+		}
+		q[name] = v
+	}
+
+	best, bestQ := "", 0.0
+	for _, e := range supported {
+		v, ok := q[e]
+		if !ok {
+			v = q["*"]
+		}
+		if v > bestQ {
+			best, bestQ = e, v
+		}
+	}
+	return best
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gotokatsuya/goka"
+	"github.com/gotokatsuya/goka/gokatest"
+	"github.com/valyala/fasthttp"
+)
+
+var largeText = strings.Repeat("goka compress ", 200)
+
+func compressApp() *goka.Goka {
+	g := goka.New()
+	g.Use(Compress())
+	g.Get("/text", func(c *goka.Context) error {
+		return c.String(fasthttp.StatusOK, largeText)
+	})
+	g.Get("/small", func(c *goka.Context) error {
+		return c.String(fasthttp.StatusOK, "small")
+	})
+	g.Get("/json", func(c *goka.Context) error {
+		return c.JSON(fasthttp.StatusOK, map[string]string{"text": largeText})
+	})
+	g.Get("/stream", func(c *goka.Context) error {
+		return c.Stream(fasthttp.StatusOK, goka.TextPlainCharsetUTF8, strings.NewReader(largeText))
+	})
+	g.Get("/image", func(c *goka.Context) error {
+		c.RequestCtx().SetContentType("image/png")
+		c.RequestCtx().SetBodyString(largeText)
+		return nil
+	})
+	g.Get("/encoded", func(c *goka.Context) error {
+		c.RequestCtx().Response.Header.SetContentEncoding("gzip")
+		c.RequestCtx().SetContentType(goka.TextPlain)
+		c.RequestCtx().SetBody(fasthttp.AppendGzipBytes(nil, []byte(largeText)))
+		return nil
+	})
+	return g
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	supported := DefaultCompressConfig.Encodings
+	tests := []struct{ header, want string }{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"gzip;q=1.0, br;q=0.5", "gzip"},
+		{"br;q=0, *", "zstd"},
+		{"*;q=0, deflate", "deflate"},
+		{"identity", ""},
+		{"GZIP;Q=0.3, compress", "gzip"},
+	}
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.header, supported); got != tt.want {
+			t.Errorf("%q: expected %q, got %q", tt.header, tt.want, got)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	g := compressApp()
+	tests := []struct {
+		path, accept, encoding string
+	}{
+		{"/text", "gzip", "gzip"},
+		{"/text", "deflate", "deflate"},
+		{"/text", "br", "br"},
+		{"/text", "zstd", "zstd"},
+		{"/text", "", ""},
+		{"/small", "gzip", ""},
+		{"/json", "gzip", "gzip"},
+		{"/stream", "gzip", "gzip"},
+		{"/stream", "br", "br"},
+		{"/image", "gzip", ""},
+		{"/encoded", "br", "gzip"},
+	}
+	for _, tt := range tests {
+		rec := gokatest.Do(g, goka.GET, tt.path, nil, map[string]string{goka.AcceptEncoding: tt.accept})
+		if got := rec.Header.Get(goka.ContentEncoding); got != tt.encoding {
+			t.Errorf("%s %q: expected encoding %q, got %q", tt.path, tt.accept, tt.encoding, got)
+		}
+		if rec.Header.Get(goka.Vary) != goka.AcceptEncoding {
+			t.Errorf("%s: expected Vary: Accept-Encoding, got %q", tt.path, rec.Header.Get(goka.Vary))
+		}
+		if body := rec.BodyString(); tt.path != "/small" && !strings.Contains(body, largeText) {
+			t.Errorf("%s %q: unexpected body %.40q", tt.path, tt.accept, body)
+		}
+	}
+}
+
+func TestCompressVary(t *testing.T) {
+	g := goka.New()
+	g.Use(func(c *goka.Context) error {
+		c.RequestCtx().Response.Header.Set(goka.Vary, goka.AcceptEncoding)
+		return nil
+	})
+	g.Use(Compress())
+	g.Get("/", func(c *goka.Context) error {
+		return c.String(fasthttp.StatusOK, largeText)
+	})
+	rec := gokatest.Do(g, goka.GET, "/", nil, map[string]string{goka.AcceptEncoding: "gzip"})
+	if vary := rec.Header.Values(goka.Vary); len(vary) != 1 || vary[0] != goka.AcceptEncoding {
+		t.Errorf("expected Accept-Encoding in Vary once, got %q", vary)
+	}
+}
@@ -0,0 +1,182 @@
+package middleware
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gotokatsuya/goka"
+	"github.com/valyala/fasthttp"
+)
+
+type (
+	// CORSConfig configures the CORS middleware.
+	CORSConfig struct {
+		// AllowOrigins lists the origins that may access the resource. An
+		// entry is either "*", an exact origin such as
+		// "https://example.com", or an origin with a wildcard subdomain
+		// such as "https://*.example.com". Defaults to "*".
+		AllowOrigins []string
+
+		// AllowOriginFunc, if set, decides whether an origin is allowed and
+		// AllowOrigins is ignored.
+		AllowOriginFunc func(origin string) bool
+
+		// AllowMethods lists the methods allowed in preflight responses.
+		AllowMethods []string
+
+		// AllowHeaders lists the request headers allowed in preflight
+		// responses. Defaults to the headers the preflight asks for.
+		AllowHeaders []string
+
+		// AllowCredentials lets the response be exposed when the request
+		// carries cookies or HTTP authentication. The request origin is
+		// then echoed instead of "*", so it requires explicit origins or
+		// AllowOriginFunc.
+		AllowCredentials bool
+
+		// ExposeHeaders lists the response headers clients may read.
+		ExposeHeaders []string
+
+		// MaxAge is how long, in seconds, a preflight response may be
+		// cached. Zero omits the header and a negative value sends 0.
+		MaxAge int
+	}
+
+	originPattern struct {
+		prefix, suffix string
+	}
+)
+
+var DefaultCORSConfig = CORSConfig{
+	AllowOrigins: []string{"*"},
+	AllowMethods: []string{goka.GET, goka.HEAD, goka.PUT, goka.PATCH, goka.POST, goka.DELETE},
+}
+
+// CORS adds Cross-Origin Resource Sharing headers to responses and answers
+// preflight requests with 204 No Content, whether or not an OPTIONS route
+// is registered.
+func CORS() goka.MiddlewareFunc {
+	return CORSWithConfig(DefaultCORSConfig)
+}
+
+func CORSWithConfig(config CORSConfig) goka.MiddlewareFunc {
+	if len(config.AllowOrigins) == 0 {
+		config.AllowOrigins = DefaultCORSConfig.AllowOrigins
+	}
+	if len(config.AllowMethods) == 0 {
+		config.AllowMethods = DefaultCORSConfig.AllowMethods
+	}
+
+	allowAll := false
+	exact := make(map[string]bool)
+	var patterns []originPattern
+	for _, o := range config.AllowOrigins {
+		if o == "*" {
+			allowAll = true
+		} else if i := strings.Index(o, "*"); i >= 0 {
+			patterns = append(patterns, originPattern{o[:i], o[i+1:]})
+		} else {
+			exact[strings.ToLower(o)] = true
+		}
+	}
+	if allowAll && config.AllowOriginFunc == nil && config.AllowCredentials {
+		panic("goka => CORS cannot allow credentials for every origin")
+	}
+	allowOrigin := config.AllowOriginFunc
+	if allowOrigin == nil {
+		allowOrigin = func(origin string) bool {
+			if allowAll || exact[strings.ToLower(origin)] {
+				return true
+			}
+			for _, p := range patterns {
+				if p.match(origin) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	allowMethods := strings.Join(config.AllowMethods, ",")
+	allowHeaders := strings.Join(config.AllowHeaders, ",")
+	exposeHeaders := strings.Join(config.ExposeHeaders, ",")
+	maxAge := ""
+	if config.MaxAge > 0 {
+		maxAge = strconv.Itoa(config.MaxAge)
+	} else if config.MaxAge < 0 {
+		maxAge = "0"
+	}
+	// "*" may only be sent when the response is the same for every origin.
+	wildcard := allowAll && config.AllowOriginFunc == nil
+
+	// setHeaders sets the headers of a simple request, or of a preflight
+	// request apart from those specific to preflights.
+	setHeaders := func(resp *fasthttp.Response, origin string, allowed, preflight bool) {
+		if !wildcard {
+			addVary(&resp.Header, goka.Origin)
+		}
+		if preflight {
+			addVary(&resp.Header, goka.AccessControlRequestMethod)
+			addVary(&resp.Header, goka.AccessControlRequestHeaders)
+		}
+		if !allowed {
+			return
+		}
+		if wildcard {
+			resp.Header.Set(goka.AccessControlAllowOrigin, "*")
+		} else {
+			resp.Header.Set(goka.AccessControlAllowOrigin, origin)
+		}
+		if config.AllowCredentials {
+			resp.Header.Set(goka.AccessControlAllowCredentials, "true")
+		}
+		if !preflight && exposeHeaders != "" {
+			resp.Header.Set(goka.AccessControlExposeHeaders, exposeHeaders)
+		}
+	}
+
+	return func(next goka.HandlerFunc) goka.HandlerFunc {
+		return func(c *goka.Context) error {
+			req := &c.RequestCtx().Request
+			resp := &c.RequestCtx().Response
+			origin := string(req.Header.Peek(goka.Origin))
+			preflight := req.Header.IsOptions() && len(req.Header.Peek(goka.AccessControlRequestMethod)) > 0
+			allowed := origin != "" && allowOrigin(origin)
+
+			setHeaders(resp, origin, allowed, preflight)
+			if !preflight {
+				if err := next(c); err != nil {
+					// The error handler resets the response, so handle the
+					// error here and send the CORS headers with it.
+					c.Error(err)
+					setHeaders(resp, origin, allowed, false)
+				}
+				return nil
+			}
+			if !allowed {
+				return c.NoContent(fasthttp.StatusNoContent)
+			}
+
+			resp.Header.Set(goka.AccessControlAllowMethods, allowMethods)
+			if allowHeaders != "" {
+				resp.Header.Set(goka.AccessControlAllowHeaders, allowHeaders)
+			} else if h := req.Header.Peek(goka.AccessControlRequestHeaders); len(h) > 0 {
+				resp.Header.SetBytesV(goka.AccessControlAllowHeaders, h)
+			}
+			if maxAge != "" {
+				resp.Header.Set(goka.AccessControlMaxAge, maxAge)
+			}
+			return c.NoContent(fasthttp.StatusNoContent)
+		}
+	}
+}
+
+// match reports whether origin matches the pattern with the wildcard
+// standing for one or more subdomain labels.
+func (p originPattern) match(origin string) bool {
+	if len(origin) <= len(p.prefix)+len(p.suffix) ||
+		!strings.HasPrefix(origin, p.prefix) || !strings.HasSuffix(origin, p.suffix) {
+		return false
+	}
+	sub := origin[len(p.prefix) : len(origin)-len(p.suffix)]
+	return !strings.ContainsAny(sub, "/:@") && sub[0] != '.' && sub[len(sub)-1] != '.'
+}
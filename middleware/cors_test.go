@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/gotokatsuya/goka"
+	"github.com/gotokatsuya/goka/gokatest"
+	"github.com/valyala/fasthttp"
+)
+
+func corsApp(config CORSConfig) *goka.Goka {
+	g := goka.New()
+	g.Use(CORSWithConfig(config))
+	g.Get("/users", func(c *goka.Context) error {
+		return c.String(fasthttp.StatusOK, "users")
+	})
+	return g
+}
+
+func TestCORSOrigins(t *testing.T) {
+	g := corsApp(CORSConfig{
+		AllowOrigins:     []string{"https://example.com", "https://*.example.org"},
+		AllowCredentials: true,
+		ExposeHeaders:    []string{"X-Total"},
+	})
+	tests := []struct {
+		origin, want string
+	}{
+		{"https://example.com", "https://example.com"},
+		{"https://api.example.org", "https://api.example.org"},
+		{"https://a.b.example.org", "https://a.b.example.org"},
+		{"https://example.org", ""},
+		{"https://evil.com/.example.org", ""},
+		{"http://api.example.org", ""},
+		{"https://evil.com", ""},
+	}
+	for _, tt := range tests {
+		rec := gokatest.Do(g, goka.GET, "/users", nil, map[string]string{goka.Origin: tt.origin})
+		if rec.Code != fasthttp.StatusOK {
+			t.Errorf("%s: expected 200, got %d", tt.origin, rec.Code)
+		}
+		if got := rec.Header.Get(goka.AccessControlAllowOrigin); got != tt.want {
+			t.Errorf("%s: expected allow origin %q, got %q", tt.origin, tt.want, got)
+		}
+		if rec.Header.Get(goka.Vary) != goka.Origin {
+			t.Errorf("%s: expected Vary: Origin, got %q", tt.origin, rec.Header.Get(goka.Vary))
+		}
+		if tt.want != "" && (rec.Header.Get(goka.AccessControlAllowCredentials) != "true" ||
+			rec.Header.Get(goka.AccessControlExposeHeaders) != "X-Total") {
+			t.Errorf("%s: missing credentials or exposed headers: %v", tt.origin, rec.Header)
+		}
+	}
+}
+
+func TestCORSWildcardAndFunc(t *testing.T) {
+	rec := gokatest.Do(corsApp(CORSConfig{}), goka.GET, "/users", nil, map[string]string{goka.Origin: "https://any.com"})
+	if rec.Header.Get(goka.AccessControlAllowOrigin) != "*" || rec.Header.Get(goka.Vary) != "" {
+		t.Errorf("expected wildcard origin without Vary, got %v", rec.Header)
+	}
+
+	g := corsApp(CORSConfig{AllowOriginFunc: func(origin string) bool {
+		return origin == "https://trusted.com"
+	}})
+	rec = gokatest.Do(g, goka.GET, "/users", nil, map[string]string{goka.Origin: "https://trusted.com"})
+	if rec.Header.Get(goka.AccessControlAllowOrigin) != "https://trusted.com" {
+		t.Errorf("expected predicate to allow origin, got %v", rec.Header)
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	g := corsApp(CORSConfig{
+		AllowOrigins: []string{"https://example.com"},
+		MaxAge:       600,
+	})
+	g.SetAutoOptions(false)
+	for _, path := range []string{"/users", "/missing"} {
+		rec := gokatest.Do(g, goka.OPTIONS, path, nil, map[string]string{
+			goka.Origin:                      "https://example.com",
+			goka.AccessControlRequestMethod:  goka.POST,
+			goka.AccessControlRequestHeaders: "Content-Type",
+		})
+		if rec.Code != fasthttp.StatusNoContent {
+			t.Errorf("%s: expected 204, got %d", path, rec.Code)
+		}
+		for k, v := range map[string]string{
+			goka.AccessControlAllowOrigin:  "https://example.com",
+			goka.AccessControlAllowMethods: "GET,HEAD,PUT,PATCH,POST,DELETE",
+			goka.AccessControlAllowHeaders: "Content-Type",
+			goka.AccessControlMaxAge:       "600",
+		} {
+			if got := rec.Header.Get(k); got != v {
+				t.Errorf("%s: expected %s %q, got %q", path, k, v, got)
+			}
+		}
+	}
+
+	rec := gokatest.Do(g, goka.OPTIONS, "/users", nil, map[string]string{
+		goka.Origin:                     "https://evil.com",
+		goka.AccessControlRequestMethod: goka.POST,
+	})
+	if rec.Code != fasthttp.StatusNoContent || rec.Header.Get(goka.AccessControlAllowOrigin) != "" {
+		t.Errorf("expected disallowed preflight without CORS headers, got %d %v", rec.Code, rec.Header)
+	}
+}
+
+func TestCORSErrorResponse(t *testing.T) {
+	g := corsApp(CORSConfig{AllowOrigins: []string{"https://example.com"}})
+	g.Get("/fail", func(c *goka.Context) error {
+		return goka.NewHTTPError(fasthttp.StatusBadRequest)
+	})
+	for _, path := range []string{"/fail", "/missing"} {
+		rec := gokatest.Do(g, goka.GET, path, nil, map[string]string{goka.Origin: "https://example.com"})
+		if rec.Code == fasthttp.StatusOK || rec.Header.Get(goka.AccessControlAllowOrigin) != "https://example.com" ||
+			len(rec.Header.Values(goka.Vary)) != 1 {
+			t.Errorf("%s: expected CORS headers on error response, got %d %v", path, rec.Code, rec.Header)
+		}
+	}
+}
+
+func TestCORSCredentialsWithWildcard(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for credentials with any origin")
+		}
+	}()
+	CORSWithConfig(CORSConfig{AllowCredentials: true})
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gotokatsuya/goka"
+	"github.com/valyala/fasthttp"
+)
+
+// DecompressConfig configures the Decompress middleware.
+type DecompressConfig struct {
+	// MaxSize is the largest decompressed body, in bytes, that is accepted.
+	// Larger bodies are rejected with 413 Request Entity Too Large.
+	// Defaults to 10 MB.
+	MaxSize int
+}
+
+var DefaultDecompressConfig = DecompressConfig{
+	MaxSize: 10 << 20,
+}
+
+// Decompress decodes request bodies sent with a gzip, deflate or br
+// Content-Encoding so that Context.Bind and Context.Form see the plain
+// body. Other encodings are rejected with ErrUnsupportedMediaType. It must
+// run before anything that parses the body, including MethodOverride.
+func Decompress() goka.MiddlewareFunc {
+	return DecompressWithConfig(DefaultDecompressConfig)
+}
+
+func DecompressWithConfig(config DecompressConfig) goka.MiddlewareFunc {
+	if config.MaxSize <= 0 {
+		config.MaxSize = DefaultDecompressConfig.MaxSize
+	}
+	return func(next goka.HandlerFunc) goka.HandlerFunc {
+		return func(c *goka.Context) error {
+			req := &c.RequestCtx().Request
+			ce := string(req.Header.Peek(goka.ContentEncoding))
+			if ce == "" {
+				return next(c)
+			}
+
+			body := req.Body()
+			// Encodings are listed in the order they were applied.
+			encodings := strings.Split(ce, ",")
+			for i := len(encodings) - 1; i >= 0; i-- {
+				var err error
+				if body, err = decompress(strings.TrimSpace(encodings[i]), body, config.MaxSize); err != nil {
+					return err
+				}
+			}
+			req.SetBody(body)
+			req.Header.Del(goka.ContentEncoding)
+			req.Header.SetContentLength(len(body))
+			return next(c)
+		}
+	}
+}
+
+func decompress(encoding string, body []byte, max int) ([]byte, error) {
+	var (
+		r   io.Reader
+		err error
+	)
+	switch strings.ToLower(encoding) {
+	case "identity", "":
+		return body, nil
+	case "gzip", "x-gzip":
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		// deflate is zlib-wrapped, but some clients send raw deflate.
+		if r, err = zlib.NewReader(bytes.NewReader(body)); err != nil {
+			r, err = flate.NewReader(bytes.NewReader(body)), nil
+		}
+	case "br":
+		r = brotli.NewReader(bytes.NewReader(body))
+	default:
+		return nil, goka.ErrUnsupportedMediaType
+	}
+	if err != nil {
+		return nil, goka.NewHTTPError(fasthttp.StatusBadRequest, err.Error())
+	}
+
+	b, err := io.ReadAll(io.LimitReader(r, int64(max)+1))
+	if err != nil {
+		return nil, goka.NewHTTPError(fasthttp.StatusBadRequest, err.Error())
+	}
+	if len(b) > max {
+		return nil, goka.NewHTTPError(fasthttp.StatusRequestEntityTooLarge,
+			"decompressed body exceeds "+strconv.Itoa(max)+" bytes")
+	}
+	return b, nil
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/flate"
+	"strings"
+	"testing"
+
+	"github.com/gotokatsuya/goka"
+	"github.com/gotokatsuya/goka/gokatest"
+	"github.com/valyala/fasthttp"
+)
+
+func rawDeflate(b []byte) []byte {
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	w.Write(b)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestDecompress(t *testing.T) {
+	type payload struct {
+		Name string `json:"name" form:"name"`
+	}
+	g := goka.New()
+	g.Use(DecompressWithConfig(DecompressConfig{MaxSize: 1 << 10}))
+	g.Post("/json", func(c *goka.Context) error {
+		var p payload
+		if err := c.Bind(&p); err != nil {
+			return err
+		}
+		return c.String(fasthttp.StatusOK, p.Name)
+	})
+	g.Post("/form", func(c *goka.Context) error {
+		return c.String(fasthttp.StatusOK, c.Form("name"))
+	})
+
+	json := []byte(`{"name":"goka"}`)
+	form := []byte("name=goka")
+	tests := []struct {
+		path, ct, encoding string
+		body               []byte
+		code               int
+	}{
+		{"/json", goka.ApplicationJSON, "gzip", fasthttp.AppendGzipBytes(nil, json), fasthttp.StatusOK},
+		{"/json", goka.ApplicationJSON, "deflate", fasthttp.AppendDeflateBytes(nil, json), fasthttp.StatusOK},
+		{"/json", goka.ApplicationJSON, "deflate", rawDeflate(json), fasthttp.StatusOK},
+		{"/json", goka.ApplicationJSON, "br", fasthttp.AppendBrotliBytes(nil, json), fasthttp.StatusOK},
+		{"/json", goka.ApplicationJSON, "", json, fasthttp.StatusOK},
+		{"/form", goka.ApplicationForm, "gzip", fasthttp.AppendGzipBytes(nil, form), fasthttp.StatusOK},
+		{"/form", goka.ApplicationForm, "deflate, gzip", fasthttp.AppendGzipBytes(nil, fasthttp.AppendDeflateBytes(nil, form)), fasthttp.StatusOK},
+		{"/json", goka.ApplicationJSON, "compress", json, fasthttp.StatusUnsupportedMediaType},
+		{"/json", goka.ApplicationJSON, "gzip", json, fasthttp.StatusBadRequest},
+		{"/json", goka.ApplicationJSON, "gzip", fasthttp.AppendGzipBytes(nil, []byte(strings.Repeat(" ", 2<<10))), fasthttp.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		header := map[string]string{goka.ContentType: tt.ct}
+		if tt.encoding != "" {
+			header[goka.ContentEncoding] = tt.encoding
+		}
+		rec := gokatest.Do(g, goka.POST, tt.path, tt.body, header)
+		if rec.Code != tt.code {
+			t.Errorf("%s %q: expected %d, got %d %q", tt.path, tt.encoding, tt.code, rec.Code, rec.BodyString())
+			continue
+		}
+		if tt.code == fasthttp.StatusOK && rec.BodyString() != "goka" {
+			t.Errorf("%s %q: expected decoded body, got %q", tt.path, tt.encoding, rec.BodyString())
+		}
+	}
+}
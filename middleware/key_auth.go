@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gotokatsuya/goka"
+	"github.com/valyala/fasthttp"
+)
+
+type (
+	// KeyAuthConfig configures the KeyAuth middleware.
+	KeyAuthConfig struct {
+		Skipper Skipper
+
+		// KeyLookup lists where the key is read from as comma separated
+		// "source:name" pairs, tried in order. The source is header, query
+		// or cookie, as in "header:X-API-Key,query:api_key". Defaults to
+		// "header:X-API-Key".
+		KeyLookup string
+
+		// Validator checks the key. It may store the principal with
+		// Context.Set. Required.
+		Validator KeyAuthValidator
+
+		// AuthScheme and Realm form the WWW-Authenticate challenge.
+		// Default to "ApiKey" and "Restricted".
+		AuthScheme string
+		Realm      string
+	}
+
+	KeyAuthValidator func(key string, c *goka.Context) (bool, error)
+
+	keyExtractor func(c *goka.Context) string
+)
+
+var DefaultKeyAuthConfig = KeyAuthConfig{
+	KeyLookup:  "header:X-API-Key",
+	AuthScheme: "ApiKey",
+	Realm:      defaultRealm,
+}
+
+// KeyAuth requires an API key accepted by fn and answers other requests
+// with 401 Unauthorized.
+func KeyAuth(fn KeyAuthValidator) goka.MiddlewareFunc {
+	config := DefaultKeyAuthConfig
+	config.Validator = fn
+	return KeyAuthWithConfig(config)
+}
+
+func KeyAuthWithConfig(config KeyAuthConfig) goka.MiddlewareFunc {
+	if config.Validator == nil {
+		panic("goka => key auth middleware requires a validator")
+	}
+	if config.Skipper == nil {
+		config.Skipper = DefaultSkipper
+	}
+	if config.KeyLookup == "" {
+		config.KeyLookup = DefaultKeyAuthConfig.KeyLookup
+	}
+	if config.AuthScheme == "" {
+		config.AuthScheme = DefaultKeyAuthConfig.AuthScheme
+	}
+	if config.Realm == "" {
+		config.Realm = DefaultKeyAuthConfig.Realm
+	}
+	var extractors []keyExtractor
+	for _, l := range strings.Split(config.KeyLookup, ",") {
+		extractors = append(extractors, newKeyExtractor(strings.TrimSpace(l)))
+	}
+	challenge := config.AuthScheme + " realm=" + quote(config.Realm)
+
+	return func(next goka.HandlerFunc) goka.HandlerFunc {
+		return func(c *goka.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+			for _, extract := range extractors {
+				key := extract(c)
+				if key == "" {
+					continue
+				}
+				valid, err := config.Validator(key, c)
+				if err != nil {
+					return err
+				}
+				if valid {
+					return next(c)
+				}
+				break
+			}
+			return goka.NewHTTPError(fasthttp.StatusUnauthorized).SetHeader(goka.WWWAuthenticate, challenge)
+		}
+	}
+}
+
+func newKeyExtractor(lookup string) keyExtractor {
+	source, name, ok := strings.Cut(lookup, ":")
+	if !ok || name == "" {
+		panic("goka => invalid key lookup " + lookup)
+	}
+	switch source {
+	case "header":
+		return func(c *goka.Context) string {
+			return string(c.RequestCtx().Request.Header.Peek(name))
+		}
+	case "query":
+		return func(c *goka.Context) string {
+			return c.Query(name)
+		}
+	case "cookie":
+		return func(c *goka.Context) string {
+			return string(c.RequestCtx().Request.Header.Cookie(name))
+		}
+	}
+	panic("goka => invalid key lookup " + lookup)
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/gotokatsuya/goka"
+	"github.com/gotokatsuya/goka/gokatest"
+	"github.com/valyala/fasthttp"
+)
+
+func TestKeyAuth(t *testing.T) {
+	g := goka.New()
+	g.Use(KeyAuthWithConfig(KeyAuthConfig{
+		KeyLookup: "header:X-API-Key, query:api_key, cookie:api_key",
+		Validator: func(key string, c *goka.Context) (bool, error) {
+			if key == "k1" {
+				c.Set("user", "client-1")
+				return true, nil
+			}
+			return false, nil
+		},
+	}))
+	g.Get("/", principalHandler)
+
+	tests := []struct {
+		target string
+		header map[string]string
+		code   int
+	}{
+		{"/", map[string]string{"X-API-Key": "k1"}, fasthttp.StatusOK},
+		{"/?api_key=k1", nil, fasthttp.StatusOK},
+		{"/", map[string]string{"Cookie": "api_key=k1"}, fasthttp.StatusOK},
+		{"/", map[string]string{"X-API-Key": "bad"}, fasthttp.StatusUnauthorized},
+		{"/?api_key=bad", map[string]string{"Cookie": "api_key=k1"}, fasthttp.StatusUnauthorized},
+		{"/", nil, fasthttp.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		rec := gokatest.Do(g, goka.GET, tt.target, nil, tt.header)
+		if rec.Code != tt.code {
+			t.Errorf("%s %v: expected %d, got %d", tt.target, tt.header, tt.code, rec.Code)
+			continue
+		}
+		if tt.code == fasthttp.StatusOK && rec.BodyString() != "client-1" {
+			t.Errorf("%s %v: expected principal, got %q", tt.target, tt.header, rec.BodyString())
+		}
+		if tt.code == fasthttp.StatusUnauthorized && rec.Header.Get(goka.WWWAuthenticate) != `ApiKey realm="Restricted"` {
+			t.Errorf("%s %v: unexpected challenge %q", tt.target, tt.header, rec.Header.Get(goka.WWWAuthenticate))
+		}
+	}
+}
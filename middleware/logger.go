@@ -0,0 +1,337 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gotokatsuya/goka"
+	"github.com/valyala/fasthttp"
+)
+
+type (
+	// LoggerConfig configures the Logger middleware.
+	LoggerConfig struct {
+		// SkipPaths lists request paths that are not logged. A path ending
+		// in * skips every path with that prefix.
+		SkipPaths []string
+
+		// SampleRate is the fraction of requests that are logged, between 0
+		// and 1. Responses with a 5xx status are always logged. Defaults to
+		// 1.
+		SampleRate float64
+
+		// Formatter renders an entry for Output. Defaults to
+		// CommonLogFormatter.
+		Formatter LogFormatter
+
+		// Output receives formatted entries. Defaults to os.Stdout.
+		Output io.Writer
+
+		// Handler, if set, receives entries as slog records instead of
+		// Formatter and Output.
+		Handler slog.Handler
+	}
+
+	// LogEntry describes one request.
+	LogEntry struct {
+		Time      time.Time     `json:"time"`
+		Method    string        `json:"method"`
+		URI       string        `json:"uri"`
+		Path      string        `json:"path"`
+		Protocol  string        `json:"protocol"`
+		Status    int           `json:"status"`
+		Latency   time.Duration `json:"latency"`
+		BytesIn   int           `json:"bytes_in"`
+		BytesOut  int           `json:"bytes_out"` // -1 for streams of unknown length
+		RemoteIP  string        `json:"remote_ip"`
+		RequestID string        `json:"request_id,omitempty"`
+		Referer   string        `json:"referer,omitempty"`
+		UserAgent string        `json:"user_agent,omitempty"`
+	}
+
+	// LogFormatter appends the formatted entry, including the trailing
+	// newline, to buf.
+	LogFormatter func(buf []byte, e *LogEntry) []byte
+)
+
+const (
+	// CommonLogFormat is the template of the Common Log Format.
+	CommonLogFormat = `${remote_ip} - - [${time_clf}] "${method} ${uri} ${protocol}" ${status} ${bytes_out}`
+
+	// CombinedLogFormat is the template of the Combined Log Format.
+	CombinedLogFormat = CommonLogFormat + ` "${referer}" "${user_agent}"`
+
+	clfTimeLayout = "02/Jan/2006:15:04:05 -0700"
+)
+
+var (
+	CommonLogFormatter   = TemplateFormatter(CommonLogFormat)
+	CombinedLogFormatter = TemplateFormatter(CombinedLogFormat)
+
+	DefaultLoggerConfig = LoggerConfig{
+		SampleRate: 1,
+		Formatter:  CommonLogFormatter,
+		Output:     os.Stdout,
+	}
+
+	logBufferPool = sync.Pool{
+		New: func() interface{} {
+			b := make([]byte, 0, 256)
+			return &b
+		},
+	}
+)
+
+// Logger writes an access log entry for every request. Errors returned by
+// later handlers are passed to the HTTP error handler first so that the
+// entry records the final status.
+func Logger() goka.MiddlewareFunc {
+	return LoggerWithConfig(DefaultLoggerConfig)
+}
+
+func LoggerWithConfig(config LoggerConfig) goka.MiddlewareFunc {
+	if config.SampleRate <= 0 {
+		config.SampleRate = DefaultLoggerConfig.SampleRate
+	}
+	if config.Formatter == nil {
+		config.Formatter = DefaultLoggerConfig.Formatter
+	}
+	if config.Output == nil {
+		config.Output = DefaultLoggerConfig.Output
+	}
+	var logger *slog.Logger
+	if config.Handler != nil {
+		logger = slog.New(config.Handler)
+	}
+	var mu sync.Mutex
+
+	return func(next goka.HandlerFunc) goka.HandlerFunc {
+		return func(c *goka.Context) (err error) {
+			rCtx := c.RequestCtx()
+			if skipPath(config.SkipPaths, string(rCtx.Path())) {
+				return next(c)
+			}
+
+			start := time.Now()
+			if err = next(c); err != nil {
+				c.Error(err)
+				err = nil
+			}
+			status := rCtx.Response.StatusCode()
+			if status < 500 && config.SampleRate < 1 && rand.Float64() >= config.SampleRate {
+				return
+			}
+
+			e := newLogEntry(c, start)
+			if logger != nil {
+				logger.LogAttrs(context.Background(), logLevel(status), "request", e.attrs()...)
+				return
+			}
+			bp := logBufferPool.Get().(*[]byte)
+			buf := config.Formatter((*bp)[:0], e)
+			mu.Lock()
+			config.Output.Write(buf)
+			mu.Unlock()
+			*bp = buf
+			logBufferPool.Put(bp)
+			return
+		}
+	}
+}
+
+func newLogEntry(c *goka.Context, start time.Time) *LogEntry {
+	rCtx := c.RequestCtx()
+	req := &rCtx.Request
+	e := &LogEntry{
+		Time:      start,
+		Method:    string(req.Header.Method()),
+		URI:       string(req.RequestURI()),
+		Path:      c.Path(),
+		Protocol:  string(req.Header.Protocol()),
+		Status:    rCtx.Response.StatusCode(),
+		Latency:   time.Since(start),
+		BytesIn:   req.Header.ContentLength(),
+		BytesOut:  responseSize(&rCtx.Response),
+		RemoteIP:  rCtx.RemoteIP().String(),
+		RequestID: c.RequestID(),
+		Referer:   string(req.Header.Referer()),
+		UserAgent: string(req.Header.UserAgent()),
+	}
+	if e.BytesIn < 0 {
+		e.BytesIn = len(req.Body())
+	}
+	if e.RequestID == "" {
+		e.RequestID = string(req.Header.Peek(goka.XRequestID))
+	}
+	return e
+}
+
+// responseSize returns the length of the response body without reading a
+// streamed body, whose length is -1 when unknown.
+func responseSize(resp *fasthttp.Response) int {
+	if resp.IsBodyStream() {
+		return resp.Header.ContentLength()
+	}
+	return len(resp.Body())
+}
+
+func (e *LogEntry) attrs() []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("method", e.Method),
+		slog.String("uri", e.URI),
+		slog.String("path", e.Path),
+		slog.Int("status", e.Status),
+		slog.Duration("latency", e.Latency),
+		slog.Int("bytes_in", e.BytesIn),
+		slog.Int("bytes_out", e.BytesOut),
+		slog.String("remote_ip", e.RemoteIP),
+	}
+	if e.RequestID != "" {
+		attrs = append(attrs, slog.String("request_id", e.RequestID))
+	}
+	return attrs
+}
+
+func logLevel(status int) slog.Level {
+	switch {
+	case status >= 500:
+		return slog.LevelError
+	case status >= 400:
+		return slog.LevelWarn
+	}
+	return slog.LevelInfo
+}
+
+func skipPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(path, p[:len(p)-1]) {
+				return true
+			}
+		} else if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// JSONFormatter writes each entry as a JSON object on its own line.
+func JSONFormatter(buf []byte, e *LogEntry) []byte {
+	b, _ := json.Marshal(e)
+	buf = append(buf, b...)
+	return append(buf, '\n')
+}
+
+// TemplateFormatter returns a formatter that replaces the tags in tmpl
+// with values of the entry. The tags are ${time_clf}, ${time_rfc3339},
+// ${remote_ip}, ${method}, ${uri}, ${path}, ${protocol}, ${status},
+// ${latency}, ${latency_human}, ${bytes_in}, ${bytes_out}, ${request_id},
+// ${referer} and ${user_agent}. Latency is in microseconds. Empty values
+// are written as - and quotes, backslashes and control characters in
+// values are escaped.
+func TemplateFormatter(tmpl string) LogFormatter {
+	var (
+		texts []string
+		tags  []string
+	)
+	for {
+		i := strings.Index(tmpl, "${")
+		if i < 0 {
+			break
+		}
+		j := strings.IndexByte(tmpl[i:], '}')
+		if j < 0 {
+			break
+		}
+		texts = append(texts, tmpl[:i])
+		tags = append(tags, tmpl[i+2:i+j])
+		tmpl = tmpl[i+j+1:]
+	}
+	for _, tag := range tags {
+		switch tag {
+		case "time_clf", "time_rfc3339", "remote_ip", "method", "uri", "path",
+			"protocol", "status", "latency", "latency_human", "bytes_in",
+			"bytes_out", "request_id", "referer", "user_agent":
+		default:
+			panic("goka => unknown log tag " + tag)
+		}
+	}
+	return func(buf []byte, e *LogEntry) []byte {
+		for i, tag := range tags {
+			buf = append(buf, texts[i]...)
+			buf = e.appendTag(buf, tag)
+		}
+		buf = append(buf, tmpl...)
+		return append(buf, '\n')
+	}
+}
+
+func (e *LogEntry) appendTag(buf []byte, tag string) []byte {
+	switch tag {
+	case "time_clf":
+		return e.Time.AppendFormat(buf, clfTimeLayout)
+	case "time_rfc3339":
+		return e.Time.AppendFormat(buf, time.RFC3339)
+	case "status":
+		return strconv.AppendInt(buf, int64(e.Status), 10)
+	case "latency":
+		return strconv.AppendInt(buf, int64(e.Latency/time.Microsecond), 10)
+	case "latency_human":
+		return append(buf, e.Latency.String()...)
+	case "bytes_in":
+		return strconv.AppendInt(buf, int64(e.BytesIn), 10)
+	case "bytes_out":
+		if e.BytesOut < 0 {
+			return append(buf, '-')
+		}
+		return strconv.AppendInt(buf, int64(e.BytesOut), 10)
+	}
+	var s string
+	switch tag {
+	case "remote_ip":
+		s = e.RemoteIP
+	case "method":
+		s = e.Method
+	case "uri":
+		s = e.URI
+	case "path":
+		s = e.Path
+	case "protocol":
+		s = e.Protocol
+	case "request_id":
+		s = e.RequestID
+	case "referer":
+		s = e.Referer
+	case "user_agent":
+		s = e.UserAgent
+	}
+	if s == "" {
+		return append(buf, '-')
+	}
+	return appendEscaped(buf, s)
+}
+
+// appendEscaped appends s with quotes, backslashes and control characters
+// escaped, so that client-supplied values cannot forge fields or lines.
+func appendEscaped(buf []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	for i := 0; i < len(s); i++ {
+		switch b := s[i]; {
+		case b == '"' || b == '\\':
+			buf = append(buf, '\\', b)
+		case b < 0x20 || b == 0x7f:
+			buf = append(buf, '\\', 'x', hex[b>>4], hex[b&0xf])
+		default:
+			buf = append(buf, b)
+		}
+	}
+	return buf
+}
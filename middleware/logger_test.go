@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gotokatsuya/goka"
+	"github.com/gotokatsuya/goka/gokatest"
+	"github.com/valyala/fasthttp"
+)
+
+func loggerApp(config LoggerConfig) *goka.Goka {
+	g := goka.New()
+	g.Use(LoggerWithConfig(config))
+	g.Get("/users/:id", func(c *goka.Context) error {
+		return c.String(fasthttp.StatusOK, "user")
+	})
+	g.Get("/health", func(c *goka.Context) error {
+		return c.NoContent(fasthttp.StatusOK)
+	})
+	g.Get("/fail", func(c *goka.Context) error {
+		return goka.NewHTTPError(fasthttp.StatusServiceUnavailable)
+	})
+	return g
+}
+
+func TestLoggerFormats(t *testing.T) {
+	tests := []struct {
+		formatter LogFormatter
+		want      string
+	}{
+		{CommonLogFormatter, `^127\.0\.0\.1 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /users/1\?q=1 HTTP/1\.1" 200 4\n$`},
+		{CombinedLogFormatter, `" 200 4 "https://example\.com/" "test-agent"\n$`},
+		{TemplateFormatter("${path} ${request_id} ${bytes_in} ${latency}us"), `^/users/:id abc 0 \d+us\n$`},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		g := loggerApp(LoggerConfig{Formatter: tt.formatter, Output: &buf})
+		gokatest.Do(g, goka.GET, "/users/1?q=1", nil, map[string]string{
+			"Referer":       "https://example.com/",
+			"User-Agent":    "test-agent",
+			goka.XRequestID: "abc",
+		})
+		if !regexp.MustCompile(tt.want).MatchString(buf.String()) {
+			t.Errorf("expected log to match %s, got %q", tt.want, buf.String())
+		}
+	}
+}
+
+func TestLoggerJSON(t *testing.T) {
+	var buf bytes.Buffer
+	g := loggerApp(LoggerConfig{Formatter: JSONFormatter, Output: &buf})
+	gokatest.Do(g, goka.GET, "/fail", nil, nil)
+
+	var e LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &e); err != nil {
+		t.Fatalf("invalid JSON line %q: %v", buf.String(), err)
+	}
+	if e.Method != goka.GET || e.Path != "/fail" || e.Status != fasthttp.StatusServiceUnavailable || e.RemoteIP != "127.0.0.1" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+}
+
+func TestLoggerSlog(t *testing.T) {
+	var buf bytes.Buffer
+	g := loggerApp(LoggerConfig{Handler: slog.NewJSONHandler(&buf, nil)})
+	gokatest.Do(g, goka.GET, "/fail", nil, nil)
+
+	var rec map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("invalid slog output %q: %v", buf.String(), err)
+	}
+	if rec["level"] != "ERROR" || rec["msg"] != "request" || rec["path"] != "/fail" || rec["status"] != float64(503) {
+		t.Errorf("unexpected record %v", rec)
+	}
+}
+
+func TestLoggerSkipAndSample(t *testing.T) {
+	var buf bytes.Buffer
+	g := loggerApp(LoggerConfig{
+		SkipPaths:  []string{"/health", "/users/*"},
+		SampleRate: 1e-9,
+		Output:     &buf,
+	})
+	gokatest.Do(g, goka.GET, "/health", nil, nil)
+	gokatest.Do(g, goka.GET, "/users/1", nil, nil)
+	gokatest.Do(g, goka.GET, "/missing", nil, nil)
+	if buf.Len() != 0 {
+		t.Errorf("expected skipped and unsampled requests not to be logged, got %q", buf.String())
+	}
+
+	gokatest.Do(g, goka.GET, "/fail", nil, nil)
+	if !strings.Contains(buf.String(), `"GET /fail HTTP/1.1" 503`) {
+		t.Errorf("expected server errors to bypass sampling, got %q", buf.String())
+	}
+}
+
+func TestLoggerStream(t *testing.T) {
+	var buf bytes.Buffer
+	g := loggerApp(LoggerConfig{Output: &buf})
+	g.Get("/stream", func(c *goka.Context) error {
+		return c.Stream(fasthttp.StatusOK, goka.TextPlain, strings.NewReader(strings.Repeat("x", 1<<20)))
+	})
+
+	rCtx := gokatest.NewRequest(goka.GET, "/stream", nil)
+	g.Serve(rCtx)
+	if !rCtx.Response.IsBodyStream() {
+		t.Error("expected the logger to leave the body stream unread")
+	}
+	if !strings.HasSuffix(buf.String(), `" 200 -`+"\n") {
+		t.Errorf("expected unknown size for streamed body, got %q", buf.String())
+	}
+}
+
+func TestLoggerEscapesValues(t *testing.T) {
+	var buf bytes.Buffer
+	g := loggerApp(LoggerConfig{Formatter: CombinedLogFormatter, Output: &buf})
+	rCtx := gokatest.NewRequest(goka.GET, "/users/1", nil)
+	rCtx.Request.Header.Set("User-Agent", `evil" 200 0 "x`)
+	rCtx.Request.Header.Set("Referer", `a\b`)
+	g.Serve(rCtx)
+
+	e := &LogEntry{UserAgent: "a\nb\x7f"}
+	if got := string(e.appendTag(nil, "user_agent")); got != `a\x0ab\x7f` {
+		t.Errorf("expected control characters to be escaped, got %q", got)
+	}
+	if !strings.HasSuffix(buf.String(), `"a\\b" "evil\" 200 0 \"x"`+"\n") {
+		t.Errorf("expected escaped quoted fields, got %q", buf.String())
+	}
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gotokatsuya/goka"
+)
+
+type (
+	// MethodOverrideConfig configures the MethodOverride middleware.
+	MethodOverrideConfig struct {
+		// Getter returns the method a POST request should be routed as, or
+		// "" to keep POST. The default reads the X-HTTP-Method-Override
+		// header and then the _method form field.
+		Getter MethodOverrideGetter
+	}
+
+	MethodOverrideGetter func(c *goka.Context) string
+)
+
+var DefaultMethodOverrideConfig = MethodOverrideConfig{
+	Getter: methodFromAny(MethodFromHeader(goka.XHTTPMethodOverride), MethodFromForm("_method")),
+}
+
+// MethodOverride lets clients that can only send POST route a request as
+// another method. Register it with Goka.Pre so it runs before routing.
+func MethodOverride() goka.MiddlewareFunc {
+	return MethodOverrideWithConfig(DefaultMethodOverrideConfig)
+}
+
+func MethodOverrideWithConfig(config MethodOverrideConfig) goka.MiddlewareFunc {
+	if config.Getter == nil {
+		config.Getter = DefaultMethodOverrideConfig.Getter
+	}
+	return func(next goka.HandlerFunc) goka.HandlerFunc {
+		return func(c *goka.Context) error {
+			req := &c.RequestCtx().Request
+			if req.Header.IsPost() {
+				if m := strings.ToUpper(config.Getter(c)); m != "" {
+					req.Header.SetMethod(m)
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+func MethodFromHeader(header string) MethodOverrideGetter {
+	return func(c *goka.Context) string {
+		return string(c.RequestCtx().Request.Header.Peek(header))
+	}
+}
+
+func MethodFromForm(param string) MethodOverrideGetter {
+	return func(c *goka.Context) string {
+		return c.Form(param)
+	}
+}
+
+func MethodFromQuery(param string) MethodOverrideGetter {
+	return func(c *goka.Context) string {
+		return c.Query(param)
+	}
+}
+
+func methodFromAny(getters ...MethodOverrideGetter) MethodOverrideGetter {
+	return func(c *goka.Context) string {
+		for _, g := range getters {
+			if m := g(c); m != "" {
+				return m
+			}
+		}
+		return ""
+	}
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/gotokatsuya/goka"
+	"github.com/gotokatsuya/goka/gokatest"
+	"github.com/valyala/fasthttp"
+)
+
+func TestMethodOverride(t *testing.T) {
+	g := goka.New()
+	g.Pre(MethodOverride())
+	g.Post("/users/:id", func(c *goka.Context) error {
+		return c.String(fasthttp.StatusOK, "post")
+	})
+	g.Delete("/users/:id", func(c *goka.Context) error {
+		return c.String(fasthttp.StatusOK, "delete")
+	})
+	g.Put("/users/:id", func(c *goka.Context) error {
+		return c.String(fasthttp.StatusOK, "put")
+	})
+
+	tests := []struct {
+		method string
+		header map[string]string
+		body   string
+		want   string
+	}{
+		{goka.POST, nil, "", "post"},
+		{goka.POST, map[string]string{goka.XHTTPMethodOverride: "DELETE"}, "", "delete"},
+		{goka.POST, map[string]string{goka.ContentType: goka.ApplicationForm}, "_method=put", "put"},
+		{goka.GET, map[string]string{goka.XHTTPMethodOverride: "DELETE"}, "", "Method Not Allowed"},
+	}
+	for _, tt := range tests {
+		var body []byte
+		if tt.body != "" {
+			body = []byte(tt.body)
+		}
+		rec := gokatest.Do(g, tt.method, "/users/1", body, tt.header)
+		if got := rec.BodyString(); got != tt.want {
+			t.Errorf("%s %v: expected %q, got %q", tt.method, tt.header, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gotokatsuya/goka"
+	"github.com/valyala/fasthttp"
+)
+
+// Skipper reports whether a middleware should be skipped for a request,
+// for example for public routes.
+type Skipper func(c *goka.Context) bool
+
+// DefaultSkipper never skips.
+func DefaultSkipper(c *goka.Context) bool {
+	return false
+}
+
+// quote returns s as an HTTP quoted-string.
+func quote(s string) string {
+	b := make([]byte, 0, len(s)+2)
+	b = append(b, '"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			b = append(b, '\\')
+		}
+		b = append(b, s[i])
+	}
+	return string(append(b, '"'))
+}
+
+// addVary adds value to the Vary header unless it is already listed.
+func addVary(h *fasthttp.ResponseHeader, value string) {
+	for _, v := range h.PeekAll(goka.Vary) {
+		for _, f := range strings.Split(string(v), ",") {
+			if strings.EqualFold(strings.TrimSpace(f), value) {
+				return
+			}
+		}
+	}
+	h.Add(goka.Vary, value)
+}
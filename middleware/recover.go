@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/gotokatsuya/goka"
+)
+
+type (
+	// RecoverConfig configures the Recover middleware.
+	RecoverConfig struct {
+		// StackSize is the maximum number of bytes of stack trace captured.
+		// Defaults to 4 KB.
+		StackSize int
+
+		// StackAll captures the stacks of all goroutines instead of only
+		// the panicking one.
+		StackAll bool
+
+		// Reporter, if set, is called with every recovered panic, for
+		// example to send it to an error tracker.
+		Reporter func(c *goka.Context, err *PanicError)
+	}
+
+	// PanicError is the error a recovered panic is converted to. The
+	// default error handler answers it with 500 Internal Server Error and,
+	// when Goka.Debug is true, the panic value and stack trace.
+	PanicError struct {
+		Value interface{}
+		Stack []byte
+	}
+)
+
+var DefaultRecoverConfig = RecoverConfig{
+	StackSize: 4 << 10,
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v\n\n%s", e.Value, e.Stack)
+}
+
+// Recover turns panics in later middleware and handlers into a
+// PanicError that is passed to the HTTP error handler. Register it with
+// Goka.Pre to also cover pre-routing middleware.
+func Recover() goka.MiddlewareFunc {
+	return RecoverWithConfig(DefaultRecoverConfig)
+}
+
+func RecoverWithConfig(config RecoverConfig) goka.MiddlewareFunc {
+	if config.StackSize <= 0 {
+		config.StackSize = DefaultRecoverConfig.StackSize
+	}
+	return func(next goka.HandlerFunc) goka.HandlerFunc {
+		return func(c *goka.Context) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					stack := make([]byte, config.StackSize)
+					stack = stack[:runtime.Stack(stack, config.StackAll)]
+					pe := &PanicError{Value: r, Stack: stack}
+					if config.Reporter != nil {
+						config.Reporter(c, pe)
+					}
+					err = pe
+				}
+			}()
+			return next(c)
+		}
+	}
+}
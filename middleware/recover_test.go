@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gotokatsuya/goka"
+	"github.com/gotokatsuya/goka/gokatest"
+	"github.com/valyala/fasthttp"
+)
+
+func TestRecover(t *testing.T) {
+	var reported *PanicError
+	g := goka.New()
+	g.Pre(RecoverWithConfig(RecoverConfig{
+		StackSize: 1 << 10,
+		Reporter: func(c *goka.Context, err *PanicError) {
+			reported = err
+		},
+	}))
+	g.Get("/panic", func(c *goka.Context) error {
+		panic("boom")
+	})
+	g.Get("/ok", func(c *goka.Context) error {
+		return c.String(fasthttp.StatusOK, "ok")
+	})
+
+	rec := gokatest.Do(g, goka.GET, "/panic", nil, nil)
+	if rec.Code != fasthttp.StatusInternalServerError || rec.BodyString() != "Internal Server Error" {
+		t.Errorf("expected 500 without details, got %d %q", rec.Code, rec.BodyString())
+	}
+	if reported == nil || reported.Value != "boom" || len(reported.Stack) == 0 || len(reported.Stack) > 1<<10 {
+		t.Errorf("unexpected reported panic %+v", reported)
+	}
+
+	g.SetDebug(true)
+	rec = gokatest.Do(g, goka.GET, "/panic", nil, nil)
+	if body := rec.BodyString(); !strings.HasPrefix(body, "panic: boom") || !strings.Contains(body, "goroutine") {
+		t.Errorf("expected panic and stack in debug body, got %q", body)
+	}
+
+	// The pooled Context keeps serving requests after a panic.
+	if rec := gokatest.Do(g, goka.GET, "/ok", nil, nil); rec.BodyString() != "ok" {
+		t.Errorf("expected ok after panic, got %q", rec.BodyString())
+	}
+}
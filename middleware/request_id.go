@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"time"
+
+	"github.com/gotokatsuya/goka"
+)
+
+// RequestIDConfig configures the RequestID middleware.
+type RequestIDConfig struct {
+	// Header is read for an incoming ID and set on the response. Defaults
+	// to X-Request-ID.
+	Header string
+
+	// Generator returns a new ID when the request has none. Defaults to
+	// GenerateUUIDv4.
+	Generator func() string
+}
+
+const (
+	maxRequestIDLength = 128
+
+	crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+)
+
+var DefaultRequestIDConfig = RequestIDConfig{
+	Header:    goka.XRequestID,
+	Generator: GenerateUUIDv4,
+}
+
+// RequestID takes the request ID from the request header, or generates
+// one, stores it with Context.SetRequestID and echoes it in the response
+// header. Incoming IDs that are too long or contain non-printable
+// characters are replaced.
+func RequestID() goka.MiddlewareFunc {
+	return RequestIDWithConfig(DefaultRequestIDConfig)
+}
+
+func RequestIDWithConfig(config RequestIDConfig) goka.MiddlewareFunc {
+	if config.Header == "" {
+		config.Header = DefaultRequestIDConfig.Header
+	}
+	if config.Generator == nil {
+		config.Generator = DefaultRequestIDConfig.Generator
+	}
+	return func(next goka.HandlerFunc) goka.HandlerFunc {
+		return func(c *goka.Context) error {
+			rCtx := c.RequestCtx()
+			id := string(rCtx.Request.Header.Peek(config.Header))
+			if !validRequestID(id) {
+				id = config.Generator()
+			}
+			c.SetRequestID(id)
+			rCtx.Response.Header.Set(config.Header, id)
+			if err := next(c); err != nil {
+				// The error handler resets the response, so handle the
+				// error here and echo the ID with it.
+				c.Error(err)
+				rCtx.Response.Header.Set(config.Header, id)
+			}
+			return nil
+		}
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func GenerateUUIDv4() string {
+	return goka.NewUUIDv4().String()
+}
+
+func GenerateUUIDv7() string {
+	return goka.NewUUIDv7().String()
+}
+
+// GenerateULID returns a ULID: a 48-bit millisecond timestamp followed by
+// 80 random bits, encoded as 26 characters of Crockford's base32.
+func GenerateULID() string {
+	var b [16]byte
+	ms := uint64(time.Now().UnixMilli())
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+	rand.Read(b[6:])
+
+	// 128 bits are encoded from the most significant end in 5-bit groups,
+	// the first group holding only the top 3 bits.
+	var s [26]byte
+	var acc uint32
+	bits := 2
+	for i, j := 0, 0; j < len(s); {
+		if bits < 5 && i < len(b) {
+			acc = acc<<8 | uint32(b[i])
+			bits += 8
+			i++
+			continue
+		}
+		bits -= 5
+		s[j] = crockford[acc>>uint(bits)&0x1f]
+		j++
+	}
+	return string(s[:])
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gotokatsuya/goka"
+	"github.com/gotokatsuya/goka/gokatest"
+	"github.com/valyala/fasthttp"
+)
+
+func TestRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	g := goka.New()
+	g.Use(RequestID(), LoggerWithConfig(LoggerConfig{
+		Formatter: TemplateFormatter("${request_id}"),
+		Output:    &buf,
+	}))
+	g.Get("/", func(c *goka.Context) error {
+		return c.String(fasthttp.StatusOK, c.RequestID())
+	})
+
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	rec := gokatest.Do(g, goka.GET, "/", nil, nil)
+	id := rec.Header.Get(goka.XRequestID)
+	if !uuid.MatchString(id) || rec.BodyString() != id || buf.String() != id+"\n" {
+		t.Errorf("expected generated UUID in header, body and log, got %q %q %q", id, rec.BodyString(), buf.String())
+	}
+
+	for in, keep := range map[string]bool{
+		"upstream-id":            true,
+		"bad id":                 false,
+		strings.Repeat("x", 129): false,
+	} {
+		rec = gokatest.Do(g, goka.GET, "/", nil, map[string]string{goka.XRequestID: in})
+		if got := rec.Header.Get(goka.XRequestID); (got == in) != keep || rec.BodyString() != got {
+			t.Errorf("%q: unexpected request ID %q", in, got)
+		}
+	}
+}
+
+func TestRequestIDConfig(t *testing.T) {
+	g := goka.New()
+	g.Use(RequestIDWithConfig(RequestIDConfig{Header: "X-Trace-ID", Generator: GenerateULID}))
+	g.Get("/", func(c *goka.Context) error {
+		return c.NoContent(fasthttp.StatusOK)
+	})
+	rec := gokatest.Do(g, goka.GET, "/", nil, nil)
+	if id := rec.Header.Get("X-Trace-ID"); !regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`).MatchString(id) {
+		t.Errorf("expected ULID in custom header, got %q", id)
+	}
+	if id := GenerateUUIDv7(); id[14] != '7' {
+		t.Errorf("expected version 7 UUID, got %s", id)
+	}
+}
+
+func TestGenerateULID(t *testing.T) {
+	id := GenerateULID()
+	var ms int64
+	for _, ch := range id[:10] {
+		ms = ms<<5 | int64(strings.IndexRune(crockford, ch))
+	}
+	if d := time.Since(time.UnixMilli(ms)); d < 0 || d > time.Minute {
+		t.Errorf("unexpected ULID timestamp %v in %s", time.UnixMilli(ms), id)
+	}
+	if GenerateULID() == id {
+		t.Error("expected distinct ULIDs")
+	}
+}
+
+func TestRequestIDErrorResponse(t *testing.T) {
+	g := goka.New()
+	g.Use(RequestID())
+	rec := gokatest.Do(g, goka.GET, "/missing", nil, map[string]string{goka.XRequestID: "upstream-id"})
+	if rec.Code != fasthttp.StatusNotFound || rec.Header.Get(goka.XRequestID) != "upstream-id" {
+		t.Errorf("expected request ID on error response, got %d %v", rec.Code, rec.Header)
+	}
+}
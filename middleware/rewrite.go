@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gotokatsuya/goka"
+	"github.com/valyala/fasthttp"
+)
+
+type (
+	// RewriteConfig configures the Rewrite middleware.
+	RewriteConfig struct {
+		// Rules maps path patterns to their replacement. A * in a pattern
+		// matches any characters and is referred to as $1, $2, ... in the
+		// replacement, as in "/old/*": "/new/$1". Longer patterns are tried
+		// first and only the first matching rule is applied.
+		Rules map[string]string
+	}
+
+	rewriteRule struct {
+		pattern *regexp.Regexp
+		replace string
+	}
+)
+
+// Rewrite rewrites the request path according to rules before routing.
+// Register it with Goka.Pre.
+func Rewrite(rules map[string]string) goka.MiddlewareFunc {
+	return RewriteWithConfig(RewriteConfig{Rules: rules})
+}
+
+func RewriteWithConfig(config RewriteConfig) goka.MiddlewareFunc {
+	rules := compileRewriteRules(config.Rules)
+	return func(next goka.HandlerFunc) goka.HandlerFunc {
+		return func(c *goka.Context) error {
+			uri := c.RequestCtx().URI()
+			path := string(uri.Path())
+			for _, r := range rules {
+				if r.pattern.MatchString(path) {
+					uri.SetPath(r.pattern.ReplaceAllString(path, r.replace))
+					break
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+func compileRewriteRules(m map[string]string) []rewriteRule {
+	patterns := make([]string, 0, len(m))
+	for p := range m {
+		patterns = append(patterns, p)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+	rules := make([]rewriteRule, len(patterns))
+	for i, p := range patterns {
+		re := strings.Replace(regexp.QuoteMeta(p), `\*`, "(.*)", -1)
+		rules[i] = rewriteRule{
+			pattern: regexp.MustCompile("^" + re + "$"),
+			replace: m[p],
+		}
+	}
+	return rules
+}
+
+// StripPrefix removes prefix from the request path before routing, so an
+// application mounted below prefix can register its routes without it.
+// Requests outside prefix get 404 Not Found. Register it with Goka.Pre.
+func StripPrefix(prefix string) goka.MiddlewareFunc {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return func(next goka.HandlerFunc) goka.HandlerFunc {
+		return func(c *goka.Context) error {
+			uri := c.RequestCtx().URI()
+			path := string(uri.Path())
+			if !strings.HasPrefix(path, prefix) ||
+				len(path) > len(prefix) && path[len(prefix)] != '/' {
+				return goka.NewHTTPError(fasthttp.StatusNotFound)
+			}
+			path = path[len(prefix):]
+			if path == "" {
+				path = "/"
+			}
+			uri.SetPath(path)
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/gotokatsuya/goka"
+	"github.com/gotokatsuya/goka/gokatest"
+	"github.com/valyala/fasthttp"
+)
+
+func pathHandler(c *goka.Context) error {
+	return c.String(fasthttp.StatusOK, string(c.RequestCtx().Path())+"?"+c.Query("q"))
+}
+
+func TestRewrite(t *testing.T) {
+	g := goka.New()
+	g.Pre(Rewrite(map[string]string{
+		"/old/*":         "/new/$1",
+		"/old/special/*": "/special/$1",
+		"/users/*/posts": "/users/$1",
+	}))
+	g.Get("/new/*", pathHandler)
+	g.Get("/special/*", pathHandler)
+	g.Get("/users/:id", pathHandler)
+
+	tests := []struct{ target, want string }{
+		{"/old/a/b?q=1", "/new/a/b?1"},
+		{"/old/special/x", "/special/x?"},
+		{"/users/1/posts", "/users/1?"},
+	}
+	for _, tt := range tests {
+		rec := gokatest.Do(g, goka.GET, tt.target, nil, nil)
+		if got := rec.BodyString(); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.target, tt.want, got)
+		}
+	}
+}
+
+func TestStripPrefix(t *testing.T) {
+	g := goka.New()
+	g.Pre(StripPrefix("/api/"))
+	g.Get("/", pathHandler)
+	g.Get("/users", pathHandler)
+
+	tests := []struct {
+		target string
+		code   int
+		want   string
+	}{
+		{"/api/users", fasthttp.StatusOK, "/users?"},
+		{"/api", fasthttp.StatusOK, "/?"},
+		{"/apiusers", fasthttp.StatusNotFound, "Not Found"},
+		{"/users", fasthttp.StatusNotFound, "Not Found"},
+	}
+	for _, tt := range tests {
+		rec := gokatest.Do(g, goka.GET, tt.target, nil, nil)
+		if rec.Code != tt.code || rec.BodyString() != tt.want {
+			t.Errorf("%s: expected %d %q, got %d %q", tt.target, tt.code, tt.want, rec.Code, rec.BodyString())
+		}
+	}
+}
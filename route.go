@@ -0,0 +1,269 @@
+package goka
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/valyala/fasthttp"
+)
+
+var ErrRouteNotFound = errors.New("route not found")
+
+func (r *Route) Name(name string) *Route {
+	if rt, ok := r.router.names[name]; ok && rt != r {
+		panic("goka => duplicate route name " + name)
+	}
+	if r.name != "" {
+		delete(r.router.names, r.name)
+	}
+	r.name = name
+	r.router.names[name] = r
+	return r
+}
+
+func (r *Route) RouteName() string {
+	return r.name
+}
+
+// Reverse returns the URI of the route registered under name. Params fill
+// the path parameters in order, or by name when a map[string]interface{}
+// or map[string]string is passed; map entries that are not path parameters
+// and url.Values are added to the query string. The wildcard is named "*".
+func (g *Goka) Reverse(name string, params ...interface{}) (string, error) {
+	r, ok := g.router.names[name]
+	if !ok {
+		return "", ErrRouteNotFound
+	}
+	return r.reverse(params, true)
+}
+
+// reverse builds the URI of r from params. Unless strict is set, missing
+// params are left as written in the route path and extra ones are ignored.
+func (r *Route) reverse(params []interface{}, strict bool) (string, error) {
+	var (
+		positional []interface{}
+		named      = map[string]string{}
+		query      = url.Values{}
+	)
+	for _, p := range params {
+		switch p := p.(type) {
+		case map[string]interface{}:
+			for k, v := range p {
+				named[k] = fmt.Sprint(v)
+			}
+		case map[string]string:
+			for k, v := range p {
+				named[k] = v
+			}
+		case url.Values:
+			for k, vs := range p {
+				query[k] = append(query[k], vs...)
+			}
+		default:
+			positional = append(positional, p)
+		}
+	}
+
+	uri := make([]byte, 0, len(r.Path)+16)
+	n := 0
+	value := func(name string) (string, error) {
+		if v, ok := named[name]; ok {
+			delete(named, name)
+			return v, nil
+		}
+		if n < len(positional) {
+			n++
+			return fmt.Sprint(positional[n-1]), nil
+		}
+		return "", fmt.Errorf("goka: missing parameter %q for route %s", name, r.Path)
+	}
+	for i, l := 0, len(r.Path); i < l; i++ {
+		switch r.Path[i] {
+		case ':':
+			name, _, end := parseParam(r.Path, i+1)
+			v, err := value(name)
+			if err != nil {
+				if strict {
+					return "", err
+				}
+				uri = append(uri, r.Path[i:end]...)
+			} else {
+				uri = append(uri, url.PathEscape(v)...)
+			}
+			i = end - 1
+		case '*':
+			v, err := value("*")
+			if err != nil {
+				if strict {
+					return "", err
+				}
+				v = "*"
+			}
+			segs := strings.Split(v, "/")
+			for j, s := range segs {
+				segs[j] = url.PathEscape(s)
+			}
+			uri = append(uri, strings.Join(segs, "/")...)
+			i = l
+		default:
+			uri = append(uri, r.Path[i])
+		}
+	}
+	if strict && n < len(positional) {
+		return "", fmt.Errorf("goka: too many parameters for route %s", r.Path)
+	}
+
+	keys := make([]string, 0, len(named))
+	for k := range named {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		query.Add(k, named[k])
+	}
+	if len(query) > 0 {
+		uri = append(uri, '?')
+		uri = append(uri, query.Encode()...)
+	}
+	return string(uri), nil
+}
+
+// Use adds middleware that runs only for this route, after the global and
+// group middleware.
+func (r *Route) Use(m ...Middleware) *Route {
+	for _, h := range m {
+		r.middleware = append(r.middleware, wrapMiddleware(h))
+	}
+	return r.update()
+}
+
+func (r *Route) Meta(key string, val interface{}) *Route {
+	if r.meta == nil {
+		r.meta = make(map[string]interface{})
+	}
+	r.meta[key] = val
+	return r
+}
+
+func (r *Route) MetaValue(key string) interface{} {
+	return r.meta[key]
+}
+
+// Timeout makes the route fail with 503 Service Unavailable when its
+// handler runs longer than d. The handler runs on its own copy of the
+// Context and of the fasthttp.RequestCtx, whose response is only used if
+// the handler finishes in time, and it should stop once the Context is
+// done. Connection state such as TLS and Hijack is not available to it.
+func (r *Route) Timeout(d time.Duration) *Route {
+	r.timeout = d
+	return r.update()
+}
+
+func (r *Route) TimeoutDuration() time.Duration {
+	return r.timeout
+}
+
+// BodyLimit rejects requests whose body is larger than n bytes with 413
+// Request Entity Too Large.
+func (r *Route) BodyLimit(n int) *Route {
+	r.bodyLimit = n
+	return r.update()
+}
+
+func (r *Route) BodyLimitSize() int {
+	return r.bodyLimit
+}
+
+func (r *Route) update() *Route {
+	h := r.handler
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		h = r.middleware[i](h)
+	}
+	if r.timeout > 0 {
+		h = timeoutHandler(h, r.timeout)
+	}
+	if r.bodyLimit > 0 {
+		h = bodyLimitHandler(h, r.bodyLimit)
+	}
+	r.node.addHandler(r.Method, r.router.goka.chain(r.group, h))
+	return r
+}
+
+func timeoutHandler(h HandlerFunc, d time.Duration) HandlerFunc {
+	return func(c *Context) error {
+		ctx, cancel := context.WithTimeout(c.Context, d)
+		defer cancel()
+
+		// The handler runs on its own copies of c and of the request, so
+		// that the middleware around the route can keep using them after a
+		// timeout while the handler is still running.
+		rCtx := new(fasthttp.RequestCtx)
+		rCtx.Init(&c.requestCtx.Request, c.requestCtx.RemoteAddr(), nil)
+		c.requestCtx.VisitUserValues(func(k []byte, v interface{}) {
+			rCtx.SetUserValueBytes(k, v)
+		})
+		rCtx.Response.SkipBody = c.requestCtx.Response.SkipBody
+		hc := *c
+		hc.Context = ctx
+		hc.requestCtx = rCtx
+		if c.store != nil {
+			hc.store = make(store, len(c.store))
+			for k, v := range c.store {
+				hc.store[k] = v
+			}
+		}
+
+		done := make(chan error, 1)
+		panicked := make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					panicked <- r
+				}
+			}()
+			done <- h(&hc)
+		}()
+		select {
+		case err := <-done:
+			hc.Context, hc.requestCtx = c.Context, c.requestCtx
+			*c = hc
+			copyResponse(&c.requestCtx.Response, &rCtx.Response)
+			return err
+		case r := <-panicked:
+			// Panic again on the request goroutine so that Recover sees it.
+			hc.Context, hc.requestCtx = c.Context, c.requestCtx
+			*c = hc
+			panic(r)
+		case <-ctx.Done():
+			// The handler still uses the param values of c, so c must not
+			// be reused.
+			c.abandoned = true
+			return NewHTTPError(fasthttp.StatusServiceUnavailable)
+		}
+	}
+}
+
+// copyResponse copies src to dst, passing on a body stream instead of
+// reading it.
+func copyResponse(dst, src *fasthttp.Response) {
+	src.CopyTo(dst)
+	if src.IsBodyStream() {
+		dst.SetBodyStream(src.BodyStream(), src.Header.ContentLength())
+	}
+}
+
+func bodyLimitHandler(h HandlerFunc, n int) HandlerFunc {
+	return func(c *Context) error {
+		req := &c.requestCtx.Request
+		if req.Header.ContentLength() > n || len(req.Body()) > n {
+			return NewHTTPError(fasthttp.StatusRequestEntityTooLarge)
+		}
+		return h(c)
+	}
+}
@@ -0,0 +1,226 @@
+package goka
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+func TestGokaReverse(t *testing.T) {
+	g := New()
+	h := func(c *Context) error { return nil }
+	g.Get("/users/:id<int>", h).Name("user.show")
+	g.Get("/users/:id/posts/:slug", h).Name("post.show")
+	g.Get("/files/:name.:ext", h).Name("file")
+	g.Get("/static/*", h).Name("static")
+
+	tests := []struct {
+		name   string
+		params []interface{}
+		uri    string
+	}{
+		{"user.show", []interface{}{1}, "/users/1"},
+		{"post.show", []interface{}{1, "hello world"}, "/users/1/posts/hello%20world"},
+		{"post.show", []interface{}{map[string]interface{}{"slug": "a/b", "id": 2, "page": 3}}, "/users/2/posts/a%2Fb?page=3"},
+		{"file", []interface{}{map[string]string{"name": "app", "ext": "js"}}, "/files/app.js"},
+		{"static", []interface{}{"css/a b.css", url.Values{"v": {"1"}}}, "/static/css/a%20b.css?v=1"},
+	}
+	for _, tt := range tests {
+		uri, err := g.Reverse(tt.name, tt.params...)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if uri != tt.uri {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.uri, uri)
+		}
+	}
+
+	if _, err := g.Reverse("post.show", 1); err == nil {
+		t.Error("expected error for missing param")
+	}
+	if _, err := g.Reverse("user.show", 1, 2); err == nil {
+		t.Error("expected error for extra param")
+	}
+	if _, err := g.Reverse("nothing"); err != ErrRouteNotFound {
+		t.Errorf("expected ErrRouteNotFound, got %v", err)
+	}
+
+	// URI resolves the first route registered with the handler.
+	if uri := g.URI(h, 5); uri != "/users/5" {
+		t.Errorf("unexpected URI %s", uri)
+	}
+}
+
+func TestRouteOptions(t *testing.T) {
+	g := New()
+	var order []string
+	g.Use(func(c *Context) error {
+		order = append(order, "global")
+		return nil
+	})
+	r := g.Post("/upload", func(c *Context) error {
+		order = append(order, "handler")
+		if c.Route().MetaValue("scope") != "write" {
+			t.Errorf("unexpected meta %v", c.Route().MetaValue("scope"))
+		}
+		return c.NoContent(fasthttp.StatusCreated)
+	}).Name("upload").Meta("scope", "write").BodyLimit(4).Use(func(c *Context) error {
+		order = append(order, "route")
+		return nil
+	})
+
+	if g.Routes()[0] != r || r.RouteName() != "upload" || r.BodyLimitSize() != 4 {
+		t.Errorf("unexpected route %+v", g.Routes()[0])
+	}
+
+	rCtx := new(fasthttp.RequestCtx)
+	rCtx.Request.Header.SetMethod(POST)
+	rCtx.Request.SetRequestURI("/upload")
+	rCtx.Request.SetBodyString("abc")
+	g.Serve(rCtx)
+	if rCtx.Response.StatusCode() != fasthttp.StatusCreated {
+		t.Errorf("expected 201, got %d", rCtx.Response.StatusCode())
+	}
+	if strings.Join(order, ",") != "global,route,handler" {
+		t.Errorf("unexpected order %v", order)
+	}
+
+	rCtx.Response.Reset()
+	rCtx.Request.SetBodyString("abcdef")
+	g.Serve(rCtx)
+	if rCtx.Response.StatusCode() != fasthttp.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %d", rCtx.Response.StatusCode())
+	}
+}
+
+func TestRouteTimeout(t *testing.T) {
+	g := New()
+	g.Get("/slow", func(c *Context) error {
+		select {
+		case <-c.Done():
+		case <-time.After(time.Second):
+		}
+		return c.String(fasthttp.StatusOK, "slow")
+	}).Timeout(10 * time.Millisecond)
+	g.Get("/fast", func(c *Context) error {
+		return c.String(fasthttp.StatusOK, "fast")
+	}).Timeout(time.Second)
+
+	ln := fasthttputil.NewInmemoryListener()
+	go g.RunListener(ln)
+	defer g.Shutdown(context.Background())
+	client := &fasthttp.Client{Dial: func(string) (net.Conn, error) { return ln.Dial() }}
+
+	for path, code := range map[string]int{
+		"/slow": fasthttp.StatusServiceUnavailable,
+		"/fast": fasthttp.StatusOK,
+	} {
+		status, _, err := client.Get(nil, "http://goka"+path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != code {
+			t.Errorf("%s: expected %d, got %d", path, code, status)
+		}
+	}
+}
+
+func TestRouteTimeoutPanic(t *testing.T) {
+	g := New()
+	g.Use(func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = c.String(fasthttp.StatusInternalServerError, r.(string))
+				}
+			}()
+			return next(c)
+		}
+	})
+	g.Get("/panic", func(c *Context) error {
+		panic("boom")
+	}).Timeout(time.Second)
+
+	rCtx := request(g, GET, "/panic")
+	if rCtx.Response.StatusCode() != fasthttp.StatusInternalServerError || string(rCtx.Response.Body()) != "boom" {
+		t.Errorf("expected panic to reach the middleware, got %d %q", rCtx.Response.StatusCode(), rCtx.Response.Body())
+	}
+}
+
+func TestRouteTimeoutContext(t *testing.T) {
+	g := New()
+	var seen interface{}
+	g.Use(func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			err := next(c)
+			seen = c.Get("handler")
+			c.Set("middleware", true)
+			return err
+		}
+	})
+	finished := make(chan struct{})
+	g.Get("/slow", func(c *Context) error {
+		<-c.Done()
+		c.Set("handler", "slow")
+		close(finished)
+		return nil
+	}).Timeout(10 * time.Millisecond)
+	g.Get("/fast", func(c *Context) error {
+		c.Set("handler", "fast")
+		return nil
+	}).Timeout(time.Second)
+
+	request(g, GET, "/slow")
+	<-finished
+	if seen != nil {
+		t.Errorf("expected middleware not to share the timed out handler's Context, got %v", seen)
+	}
+	request(g, GET, "/fast")
+	if seen != "fast" {
+		t.Errorf("expected values set by the handler to be kept, got %v", seen)
+	}
+}
+
+func TestRouteTimeoutResponse(t *testing.T) {
+	g := New()
+	var status int
+	g.Use(func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+			status = c.RequestCtx().Response.StatusCode()
+			return nil
+		}
+	})
+	finished := make(chan struct{})
+	g.Get("/slow", func(c *Context) error {
+		<-c.Done()
+		c.RequestCtx().SetStatusCode(fasthttp.StatusOK)
+		close(finished)
+		return nil
+	}).Timeout(5 * time.Millisecond)
+	g.Get("/fast", func(c *Context) error {
+		c.RequestCtx().Response.Header.Set("X-Handler", "fast")
+		return c.Stream(fasthttp.StatusCreated, TextPlain, strings.NewReader("fast"))
+	}).Timeout(time.Second)
+
+	request(g, GET, "/slow")
+	<-finished
+	if status != fasthttp.StatusServiceUnavailable {
+		t.Errorf("expected middleware to see 503, got %d", status)
+	}
+	rCtx := request(g, GET, "/fast")
+	resp := &rCtx.Response
+	if status != fasthttp.StatusCreated || string(resp.Header.Peek("X-Handler")) != "fast" || string(resp.Body()) != "fast" {
+		t.Errorf("expected the handler's response, got %d %q", status, resp.String())
+	}
+}
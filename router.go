@@ -1,10 +1,27 @@
 package goka
 
+import (
+	pathpkg "path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
 type (
 	Router struct {
-		tree   *node
-		routes []Route
-		goka   *Goka
+		tree              *node
+		routes            []*Route
+		names             map[string]*Route
+		groups            []*Group
+		goka              *Goka
+		autoHead          bool
+		autoOptions       bool
+		trailingSlash     TrailingSlashPolicy
+		cleanPath         bool
+		caseInsensitive   bool
+		redirectFixedPath bool
 	}
 	node struct {
 		kind          kind
@@ -15,7 +32,10 @@ type (
 		ppath         string
 		pnames        []string
 		methodHandler *methodHandler
-		goka          *Goka
+		group         *Group
+		constraint    string
+		pattern       *regexp.Regexp
+		routes        []*Route
 	}
 	kind          uint8
 	children      []*node
@@ -29,7 +49,10 @@ type (
 		post    HandlerFunc
 		put     HandlerFunc
 		trace   HandlerFunc
+		others  map[string]HandlerFunc
 	}
+
+	TrailingSlashPolicy uint8
 )
 
 const (
@@ -38,132 +61,191 @@ const (
 	mkind
 )
 
+const (
+	// TrailingSlashStrict treats /users and /users/ as different paths.
+	TrailingSlashStrict TrailingSlashPolicy = iota
+	// TrailingSlashRedirect redirects to the registered form of the path.
+	TrailingSlashRedirect
+	// TrailingSlashMatch serves both forms with the registered route.
+	TrailingSlashMatch
+)
+
+var constraints = map[string]string{
+	"int":   `-?[0-9]+`,
+	"uint":  `[0-9]+`,
+	"alpha": `[a-zA-Z]+`,
+	"alnum": `[a-zA-Z0-9]+`,
+	"uuid":  `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+}
+
 func NewRouter(g *Goka) *Router {
 	return &Router{
 		tree: &node{
 			methodHandler: new(methodHandler),
 		},
-		routes: []Route{},
-		goka:   g,
+		routes:      []*Route{},
+		names:       map[string]*Route{},
+		goka:        g,
+		autoHead:    true,
+		autoOptions: true,
 	}
 }
 
-func (r *Router) Add(method, path string, h HandlerFunc, g *Goka) {
+// Add registers h for method and path. A path parameter may carry a
+// constraint, either a regular expression or one of the names in
+// constraints, as in /users/:id<int> or /files/:name<[a-z0-9-]+>.
+// Parameter names end at the first character that is not a letter, digit
+// or underscore, so several parameters may share a segment, as in
+// /files/:name.:ext.
+func (r *Router) Add(method, path string, h HandlerFunc) {
+	r.goka.add(nil, method, path, h)
+}
+
+func (r *Router) add(method, path string, h HandlerFunc, grp *Group) *node {
+	if method == "" {
+		panic("goka => invalid method")
+	}
 	ppath := path
 	pnames := []string{}
+	cn := r.tree
 
+	s := 0
 	for i, l := 0, len(path); i < l; i++ {
 		if path[i] == ':' {
-			j := i + 1
-
-			r.insert(method, path[:i], nil, skind, "", nil, g)
-			for ; i < l && path[i] != '/'; i++ {
-			}
-
-			pnames = append(pnames, path[j:i])
-			path = path[:j] + path[i:]
-			i, l = j, len(path)
-
-			if i == l {
-				r.insert(method, path[:i], h, pkind, ppath, pnames, g)
-				return
-			}
-			r.insert(method, path[:i], nil, pkind, ppath, pnames, g)
+			cn = cn.insertStatic(path[s:i])
+			name, constraint, end := parseParam(path, i+1)
+			pnames = append(pnames, name)
+			cn = cn.insertParam(constraint)
+			s = end
+			i = end - 1
 		} else if path[i] == '*' {
-			r.insert(method, path[:i], nil, skind, "", nil, g)
+			cn = cn.insertStatic(path[s:i])
 			pnames = append(pnames, "_*")
-			r.insert(method, path[:i+1], h, mkind, ppath, pnames, g)
-			return
+			cn = cn.insertAny()
+			s = l
+			break
 		}
 	}
+	cn = cn.insertStatic(path[s:])
 
-	r.insert(method, path, h, skind, ppath, pnames, g)
+	if l := len(pnames); *r.goka.maxParam < l {
+		*r.goka.maxParam = l
+	}
+	cn.addHandler(method, h)
+	cn.ppath = ppath
+	cn.pnames = pnames
+	cn.group = grp
+	return cn
 }
 
-func (r *Router) insert(method, path string, h HandlerFunc, t kind, ppath string, pnames []string, g *Goka) {
-	l := len(pnames)
-	if *g.maxParam < l {
-		*g.maxParam = l
+// parseParam parses the parameter name and optional constraint starting at
+// path[i], just after the ':', and returns the index following them.
+func parseParam(path string, i int) (name, constraint string, end int) {
+	l := len(path)
+	j := i
+	for ; i < l && isParamChar(path[i]); i++ {
+	}
+	name = path[j:i]
+	// A name followed by "-" and more name characters, such as :user-id,
+	// would otherwise silently become the param :user and a static "-id".
+	if i+1 < l && path[i] == '-' && isParamChar(path[i+1]) {
+		panic("goka => param names may not contain '-' in " + path)
 	}
 
-	cn := r.tree
-	if cn == nil {
-		panic("goka => invalid method")
+	if i < l && path[i] == '<' {
+		k := i + 1
+		for depth := 1; ; {
+			i++
+			if i == l {
+				panic("goka => unterminated constraint in " + path)
+			}
+			if path[i] == '<' {
+				depth++
+			} else if path[i] == '>' {
+				if depth--; depth == 0 {
+					break
+				}
+			}
+		}
+		constraint = path[k:i]
+		i++
 	}
-	search := path
+	return name, constraint, i
+}
+
+func isParamChar(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
 
-	for {
-		sl := len(search)
-		pl := len(cn.prefix)
-		l := 0
+func (n *node) insertStatic(path string) *node {
+	cn := n
+	for path != "" {
+		c := cn.findChild(path[0], skind)
+		if c == nil {
+			c = newNode(skind, path, cn, nil, new(methodHandler), "", nil)
+			cn.addChild(c)
+			return c
+		}
 
-		max := pl
-		if sl < max {
-			max = sl
+		l, max := 0, len(c.prefix)
+		if len(path) < max {
+			max = len(path)
 		}
-		for ; l < max && search[l] == cn.prefix[l]; l++ {
+		for ; l < max && path[l] == c.prefix[l]; l++ {
 		}
 
-		switch {
-		case l == 0:
-			cn.label = search[0]
-			cn.prefix = search
-			if h != nil {
-				cn.kind = t
-				cn.addHandler(method, h)
-				cn.ppath = ppath
-				cn.pnames = pnames
-				cn.goka = g
-			}
-		case l < pl:
-			n := newNode(cn.kind, cn.prefix[l:], cn, cn.children, cn.methodHandler, cn.ppath, cn.pnames, cn.goka)
-
-			cn.kind = skind
-			cn.label = cn.prefix[0]
-			cn.prefix = cn.prefix[:l]
-			cn.children = nil
-			cn.methodHandler = new(methodHandler)
-			cn.ppath = ""
-			cn.pnames = nil
-			cn.goka = nil
-
-			cn.addChild(n)
-
-			if l == sl {
-				cn.kind = t
-				cn.addHandler(method, h)
-				cn.ppath = ppath
-				cn.pnames = pnames
-				cn.goka = g
-			} else {
-				n = newNode(t, search[l:], cn, nil, new(methodHandler), ppath, pnames, g)
-				n.addHandler(method, h)
-				cn.addChild(n)
-			}
-		case l < sl:
-			search = search[l:]
-			c := cn.findChildWithLabel(search[0])
-			if c != nil {
-				cn = c
-				continue
-			}
-			n := newNode(t, search, cn, nil, new(methodHandler), ppath, pnames, g)
-			n.addHandler(method, h)
-			cn.addChild(n)
-		default:
-			if h != nil {
-				cn.addHandler(method, h)
-				cn.ppath = path
-				cn.pnames = pnames
-				cn.goka = g
-			}
+		if l < len(c.prefix) {
+			// Split c so that its first l bytes become a new parent.
+			sn := newNode(skind, c.prefix[:l], cn, children{c}, new(methodHandler), "", nil)
+			cn.children[cn.indexOf(c)] = sn
+			c.parent = sn
+			c.prefix = c.prefix[l:]
+			c.label = c.prefix[0]
+			c = sn
 		}
+		cn = c
+		path = path[l:]
+	}
+	return cn
+}
 
-		return
+func (n *node) insertParam(constraint string) *node {
+	for _, c := range n.children {
+		if c.kind == pkind && c.constraint == constraint {
+			return c
+		}
+	}
+	c := newNode(pkind, ":", n, nil, new(methodHandler), "", nil)
+	if constraint != "" {
+		expr := constraint
+		if e, ok := constraints[constraint]; ok {
+			expr = e
+		}
+		c.constraint = constraint
+		c.pattern = regexp.MustCompile("^(?:" + expr + ")$")
+		// Constrained params are tried before unconstrained ones.
+		i := 0
+		for ; i < len(n.children) && (n.children[i].kind != pkind || n.children[i].pattern != nil); i++ {
+		}
+		n.children = append(n.children, nil)
+		copy(n.children[i+1:], n.children[i:])
+		n.children[i] = c
+		return c
 	}
+	n.addChild(c)
+	return c
 }
 
-func newNode(t kind, pre string, p *node, c children, mh *methodHandler, ppath string, pnames []string, g *Goka) *node {
+func (n *node) insertAny() *node {
+	if c := n.findChildByKind(mkind); c != nil {
+		return c
+	}
+	c := newNode(mkind, "*", n, nil, new(methodHandler), "", nil)
+	n.addChild(c)
+	return c
+}
+
+func newNode(t kind, pre string, p *node, c children, mh *methodHandler, ppath string, pnames []string) *node {
 	return &node{
 		kind:          t,
 		label:         pre[0],
@@ -173,7 +255,6 @@ func newNode(t kind, pre string, p *node, c children, mh *methodHandler, ppath s
 		ppath:         ppath,
 		pnames:        pnames,
 		methodHandler: mh,
-		goka:          g,
 	}
 }
 
@@ -181,18 +262,18 @@ func (n *node) addChild(c *node) {
 	n.children = append(n.children, c)
 }
 
-func (n *node) findChild(l byte, t kind) *node {
-	for _, c := range n.children {
-		if c.label == l && c.kind == t {
-			return c
+func (n *node) indexOf(c *node) int {
+	for i, cc := range n.children {
+		if cc == c {
+			return i
 		}
 	}
-	return nil
+	return -1
 }
 
-func (n *node) findChildWithLabel(l byte) *node {
+func (n *node) findChild(l byte, t kind) *node {
 	for _, c := range n.children {
-		if c.label == l {
+		if c.label == l && c.kind == t {
 			return c
 		}
 	}
@@ -224,6 +305,15 @@ func (n *node) addHandler(method string, h HandlerFunc) {
 		n.methodHandler.options = h
 	case HEAD:
 		n.methodHandler.head = h
+	case CONNECT:
+		n.methodHandler.connect = h
+	case TRACE:
+		n.methodHandler.trace = h
+	default:
+		if n.methodHandler.others == nil {
+			n.methodHandler.others = make(map[string]HandlerFunc)
+		}
+		n.methodHandler.others[method] = h
 	}
 }
 
@@ -243,127 +333,358 @@ func (n *node) findHandler(method string) HandlerFunc {
 		return n.methodHandler.options
 	case HEAD:
 		return n.methodHandler.head
+	case CONNECT:
+		return n.methodHandler.connect
+	case TRACE:
+		return n.methodHandler.trace
 	default:
-		return nil
+		return n.methodHandler.others[method]
 	}
 }
 
-func (n *node) check405() HandlerFunc {
-	for _, m := range methods {
-		if h := n.findHandler(m); h != nil {
-			return methodNotAllowedHandler
+func (n *node) addRoute(r *Route) {
+	for i, rt := range n.routes {
+		if rt.Method == r.Method {
+			n.routes[i] = r
+			return
 		}
 	}
-	return notFoundHandler
+	n.routes = append(n.routes, r)
 }
 
-func (r *Router) Find(method, path string, ctx *Context) (h HandlerFunc, g *Goka) {
-	h = notFoundHandler
-	g = r.goka
-	cn := r.tree
+func (n *node) findRoute(method string) *Route {
+	for _, r := range n.routes {
+		if r.Method == method {
+			return r
+		}
+	}
+	return nil
+}
+
+func (n *node) hasHandler() bool {
+	return n.ppath != ""
+}
 
-	var (
-		search = path
-		c      *node
-		n      int
-		nk     kind
-		nn     *node
-		ns     string
-	)
+// Find returns the handler for method and path and the group of the
+// matched route, or of the longest group prefix of path when no route
+// matches. Route handlers are composed with their middleware when they are
+// registered; the 404, 405, OPTIONS and redirect handlers are wrapped on
+// each call.
+func (r *Router) Find(method, path string, ctx *Context) (h HandlerFunc, grp *Group) {
+	ctx.path = ""
+	ctx.names = nil
+	ctx.node = nil
+
+	fixed := false
+	if r.cleanPath && !isCleanPath(path) {
+		path = cleanPath(path)
+		fixed = true
+	}
 
-	for {
-		if search == "" {
-			goto End
+	m := matcher{values: ctx.values}
+	cn := m.match(r.tree, path, 0)
+	if cn == nil && r.trailingSlash != TrailingSlashStrict {
+		m.tsr = true
+		cn = m.match(r.tree, path, 0)
+	}
+	if cn == nil && r.caseInsensitive {
+		m.tsr = false
+		m.ci = true
+		if cn = m.match(r.tree, path, 0); cn == nil && r.trailingSlash != TrailingSlashStrict {
+			m.tsr = true
+			cn = m.match(r.tree, path, 0)
 		}
+		fixed = cn != nil
+	}
+	if cn == nil {
+		grp = r.groupOf(path)
+		if h = grp.notFound(); h == nil {
+			h = r.goka.notFoundHandler
+		}
+		h = r.goka.chain(grp, h)
+		return
+	}
 
-		pl := 0
-		l := 0
+	copyValues(ctx.values[:len(cn.pnames)])
+	ctx.path = cn.ppath
+	ctx.names = cn.pnames
+	ctx.node = cn
+	grp = cn.group
+	if fixed && r.redirectFixedPath || m.slash && r.trailingSlash == TrailingSlashRedirect {
+		h = r.goka.chain(grp, redirectHandler(cn.canonicalPath(ctx.values)))
+		return
+	}
+	// Routes on one path may belong to different groups, so the group of
+	// the node is only used when no route matches the method.
+	if h = cn.findHandler(method); h != nil {
+		grp = cn.findRoute(method).group
+		return
+	}
 
-		if cn.label != ':' {
-			sl := len(search)
-			pl = len(cn.prefix)
+	if method == HEAD && r.autoHead {
+		if h = cn.findHandler(GET); h != nil {
+			grp = cn.findRoute(GET).group
+			return
+		}
+	}
+	if method == OPTIONS && r.autoOptions {
+		h = optionsHandler
+	} else if h = grp.methodNotAllowed(); h == nil {
+		h = r.goka.methodNotAllowedHandler
+	}
+	if ctx.requestCtx != nil {
+		ctx.requestCtx.Response.Header.Set(Allow, cn.allow(r))
+	}
+	h = r.goka.chain(grp, h)
+	return
+}
 
-			max := pl
-			if sl < max {
-				max = sl
-			}
-			for ; l < max && search[l] == cn.prefix[l]; l++ {
-			}
+// rebuild recomposes the handler of every route after middleware changed.
+func (r *Router) rebuild() {
+	for _, rt := range r.routes {
+		rt.update()
+	}
+}
+
+// groupOf returns the group with the longest prefix of path.
+func (r *Router) groupOf(path string) (grp *Group) {
+	for _, g := range r.groups {
+		if hasPathPrefix(path, g.prefix) && (grp == nil || len(g.prefix) > len(grp.prefix)) {
+			grp = g
 		}
+	}
+	return
+}
 
-		if l == pl {
-			search = search[l:]
-		} else {
-			cn = nn
-			search = ns
-			if nk == pkind {
-				goto Param
-			} else if nk == mkind {
-				goto MatchAny
-			} else {
-				return
-			}
+// hasPathPrefix reports whether path lies below prefix, so that /api
+// matches /api and /api/users but not /apiary.
+func hasPathPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || prefix == "" ||
+		prefix[len(prefix)-1] == '/' || path[len(prefix)] == '/'
+}
+
+// allow returns the methods n can answer, for the Allow header.
+func (n *node) allow(r *Router) string {
+	var ms []string
+	for _, m := range standardMethods {
+		if n.findHandler(m) != nil ||
+			m == HEAD && r.autoHead && n.findHandler(GET) != nil ||
+			m == OPTIONS && r.autoOptions {
+			ms = append(ms, m)
 		}
+	}
+	others := make([]string, 0, len(n.methodHandler.others))
+	for m := range n.methodHandler.others {
+		others = append(others, m)
+	}
+	sort.Strings(others)
+	return strings.Join(append(ms, others...), ", ")
+}
 
-		if search == "" {
-			goto End
+// canFollow reports whether a value of param node n may be followed by b
+// within the same path segment.
+func (n *node) canFollow(b byte, ci bool) bool {
+	for _, c := range n.children {
+		if c.kind != skind || labelEqual(c.label, b, ci) {
+			return true
 		}
+	}
+	return false
+}
 
-		c = cn.findChild(search[0], skind)
-		if c != nil {
-			if cn.label == '/' {
-				nk = pkind
-				nn = cn
-				ns = search
+// canonicalPath rebuilds the path matched by n from the registered static
+// prefixes and the param values.
+func (n *node) canonicalPath(values []string) string {
+	i := len(n.pnames)
+	var parts []string
+	for cn := n; cn != nil; cn = cn.parent {
+		if cn.kind == skind {
+			parts = append(parts, cn.prefix)
+		} else {
+			i--
+			parts = append(parts, values[i])
+		}
+	}
+	b := make([]byte, 0, 64)
+	for j := len(parts) - 1; j >= 0; j-- {
+		b = append(b, parts[j]...)
+	}
+	return string(b)
+}
+
+// copyValues copies the param values, which point into the request path,
+// into one new string so that they stay valid after the request.
+func copyValues(values []string) {
+	n := 0
+	for _, v := range values {
+		n += len(v)
+	}
+	if n == 0 {
+		return
+	}
+	var b strings.Builder
+	b.Grow(n)
+	for _, v := range values {
+		b.WriteString(v)
+	}
+	s := b.String()
+	for i, v := range values {
+		values[i], s = s[:len(v)], s[len(v):]
+	}
+}
+
+// matcher holds the state of a route lookup. With tsr set a path differing
+// from a route only by a trailing slash matches and slash is set. With ci
+// set static segments match case-insensitively.
+type matcher struct {
+	values []string
+	tsr    bool
+	ci     bool
+	slash  bool
+}
+
+// match finds the route node for search below n, backtracking to sibling
+// static, param and any nodes when a branch fails to match. Param values
+// are written to m.values starting at index i.
+func (m *matcher) match(n *node, search string, i int) *node {
+	if search == "" {
+		if n.hasHandler() {
+			return n
+		}
+		if c := n.findChildByKind(mkind); c != nil {
+			m.values[i] = ""
+			return c
+		}
+		if m.tsr {
+			if c := n.findChild('/', skind); c != nil && c.prefix == "/" && c.hasHandler() {
+				m.slash = true
+				return c
 			}
-			cn = c
+		}
+		return nil
+	}
+	if m.tsr && search == "/" && n.hasHandler() {
+		m.slash = true
+		return n
+	}
+
+	for _, c := range n.children {
+		if c.kind != skind || !labelEqual(c.label, search[0], m.ci) {
 			continue
 		}
+		pl := len(c.prefix)
+		if pl <= len(search) && m.equal(search[:pl], c.prefix) {
+			if cn := m.match(c, search[pl:], i); cn != nil {
+				return cn
+			}
+		} else if m.tsr && pl == len(search)+1 && c.prefix[pl-1] == '/' && m.equal(search, c.prefix[:pl-1]) && c.hasHandler() {
+			m.slash = true
+			return c
+		}
+		if !m.ci {
+			break
+		}
+	}
 
-	Param:
-		c = cn.findChildByKind(pkind)
-		if c != nil {
-			if cn.label == '/' {
-				nk = mkind
-				nn = cn
-				ns = search
+	seg := strings.IndexByte(search, '/')
+	if seg < 0 {
+		seg = len(search)
+	}
+	for _, c := range n.children {
+		if c.kind != pkind {
+			continue
+		}
+		// Try the longest value first, then shorter ones that end where
+		// one of c's static children could start.
+		for end := seg; end > 0; end-- {
+			if end < seg && !c.canFollow(search[end], m.ci) {
+				continue
+			}
+			v := search[:end]
+			if c.pattern != nil && !c.pattern.MatchString(v) {
+				continue
 			}
-			cn = c
-			i, l := 0, len(search)
-			for ; i < l && search[i] != '/'; i++ {
+			m.values[i] = v
+			if cn := m.match(c, search[end:], i+1); cn != nil {
+				return cn
 			}
-			ctx.values[n] = search[:i]
-			n++
-			search = search[i:]
-			continue
 		}
+	}
 
-	MatchAny:
-		if cn = cn.findChildByKind(mkind); cn == nil {
-			return
-		}
-		ctx.values[len(cn.pnames)-1] = search
-		goto End
+	if c := n.findChildByKind(mkind); c != nil {
+		m.values[i] = search
+		return c
 	}
+	return nil
+}
 
-End:
-	ctx.path = cn.ppath
-	ctx.names = cn.pnames
-	h = cn.findHandler(method)
-	if cn.goka != nil {
-		g = cn.goka
+func (m *matcher) equal(a, b string) bool {
+	if m.ci {
+		return strings.EqualFold(a, b)
 	}
+	return a == b
+}
 
-	if h == nil {
-		h = cn.check405()
+func labelEqual(a, b byte, ci bool) bool {
+	if a == b {
+		return true
+	}
+	if !ci {
+		return false
+	}
+	if 'A' <= a && a <= 'Z' {
+		a += 'a' - 'A'
+	}
+	if 'A' <= b && b <= 'Z' {
+		b += 'a' - 'A'
+	}
+	return a == b
+}
 
-		if cn = cn.findChildByKind(mkind); cn == nil {
-			return
+// isCleanPath reports whether p has no empty, "." or ".." segments.
+func isCleanPath(p string) bool {
+	if p == "" || p[0] != '/' {
+		return false
+	}
+	for i := 0; i < len(p); i++ {
+		if p[i] != '/' {
+			continue
+		}
+		rest := p[i+1:]
+		if rest != "" && rest[0] == '/' {
+			return false
 		}
-		ctx.values[len(cn.pnames)-1] = ""
-		if h = cn.findHandler(method); h == nil {
-			h = cn.check405()
+		if rest == "." || rest == ".." || strings.HasPrefix(rest, "./") || strings.HasPrefix(rest, "../") {
+			return false
 		}
 	}
-	return
+	return true
+}
+
+// cleanPath is like path.Clean but keeps a trailing slash.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	c := pathpkg.Clean("/" + p)
+	if p[len(p)-1] == '/' && c != "/" {
+		c += "/"
+	}
+	return c
+}
+
+func redirectHandler(path string) HandlerFunc {
+	return func(c *Context) error {
+		code := fasthttp.StatusMovedPermanently
+		if m := c.requestCtx.Method(); string(m) != GET && string(m) != HEAD {
+			code = fasthttp.StatusPermanentRedirect
+		}
+		if q := c.requestCtx.URI().QueryString(); len(q) > 0 {
+			path += "?" + string(q)
+		}
+		return c.Redirect(code, path)
+	}
 }
@@ -0,0 +1,295 @@
+package goka
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func testHandler(name string) HandlerFunc {
+	return func(c *Context) error {
+		c.Set("route", name)
+		return nil
+	}
+}
+
+type routeTest struct {
+	method, path, route string
+	params              map[string]string
+}
+
+func checkRoutes(t *testing.T, g *Goka, tests []routeTest) {
+	for _, tt := range tests {
+		c := NewContext(nil, g)
+		h, _ := g.router.Find(tt.method, tt.path, c)
+		if err := h(c); err != nil {
+			if tt.route != "" {
+				t.Errorf("%s %s: expected %s, got %v", tt.method, tt.path, tt.route, err)
+			}
+			continue
+		}
+		if got := c.Get("route"); got != tt.route {
+			t.Errorf("%s %s: expected route %q, got %v", tt.method, tt.path, tt.route, got)
+			continue
+		}
+		for k, v := range tt.params {
+			if got := c.ParamByName(k); got != v {
+				t.Errorf("%s %s: expected %s=%q, got %q", tt.method, tt.path, k, v, got)
+			}
+		}
+	}
+}
+
+func TestRouterStaticParamAny(t *testing.T) {
+	g := New()
+	g.Get("/", testHandler("root"))
+	g.Get("/users", testHandler("users"))
+	g.Get("/users/new", testHandler("users.new"))
+	g.Get("/users/:id", testHandler("users.show"))
+	g.Get("/users/:id/posts/:pid", testHandler("posts.show"))
+	g.Get("/static/*", testHandler("static"))
+
+	checkRoutes(t, g, []routeTest{
+		{GET, "/", "root", nil},
+		{GET, "/users", "users", nil},
+		{GET, "/users/new", "users.new", nil},
+		{GET, "/users/1", "users.show", map[string]string{"id": "1"}},
+		{GET, "/users/news", "users.show", map[string]string{"id": "news"}},
+		{GET, "/users/1/posts/2", "posts.show", map[string]string{"id": "1", "pid": "2"}},
+		{GET, "/static/css/app.css", "static", map[string]string{"_*": "css/app.css"}},
+		{GET, "/static/", "static", map[string]string{"_*": ""}},
+		{GET, "/nothing", "", nil},
+		{POST, "/users", "", nil},
+	})
+}
+
+func TestRouterConstraints(t *testing.T) {
+	g := New()
+	g.Get("/users/:id<int>", testHandler("users.id"))
+	g.Get("/users/:name<[a-z-]+>", testHandler("users.name"))
+	g.Get("/files/:name<[a-z0-9-]+>/raw", testHandler("files.raw"))
+	g.Get("/files/:path/raw", testHandler("files.any"))
+	g.Get("/v:version<\\d+>/status", testHandler("status"))
+
+	checkRoutes(t, g, []routeTest{
+		{GET, "/users/42", "users.id", map[string]string{"id": "42"}},
+		{GET, "/users/john-doe", "users.name", map[string]string{"name": "john-doe"}},
+		{GET, "/users/John", "", nil},
+		{GET, "/files/a-1/raw", "files.raw", map[string]string{"name": "a-1"}},
+		{GET, "/files/A_1/raw", "files.any", map[string]string{"path": "A_1"}},
+		{GET, "/v2/status", "status", map[string]string{"version": "2"}},
+		{GET, "/vx/status", "", nil},
+	})
+}
+
+func TestRouterMultipleParamsInSegment(t *testing.T) {
+	g := New()
+	g.Get("/files/:name.:ext", testHandler("files"))
+	g.Get("/date/:year<\\d{4}>-:month-:day", testHandler("date"))
+	g.Get("/date/:year/summary", testHandler("summary"))
+	g.Get("/v:major.:minor/info", testHandler("version"))
+
+	checkRoutes(t, g, []routeTest{
+		{GET, "/files/app.js", "files", map[string]string{"name": "app", "ext": "js"}},
+		{GET, "/files/archive.tar.gz", "files", map[string]string{"name": "archive.tar", "ext": "gz"}},
+		{GET, "/files/README", "", nil},
+		{GET, "/date/2016-02-18", "date", map[string]string{"year": "2016", "month": "02", "day": "18"}},
+		{GET, "/date/2016/summary", "summary", map[string]string{"year": "2016"}},
+		{GET, "/v1.2/info", "version", map[string]string{"major": "1", "minor": "2"}},
+	})
+}
+
+func TestRouterHyphenatedParamName(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for the param name :user-id")
+		}
+	}()
+	New().Get("/users/:user-id", testHandler("user"))
+}
+
+func TestGokaURIWithMultipleParams(t *testing.T) {
+	g := New()
+	h := func(c *Context) error { return nil }
+	g.Get("/date/:year<\\d{4}>-:month-:day/events", h)
+	if uri := g.URI(h, 2016, "02", 18); uri != "/date/2016-02-18/events" {
+		t.Errorf("unexpected uri %s", uri)
+	}
+}
+
+func TestGokaURIMissingParams(t *testing.T) {
+	g := New()
+	h := func(c *Context) error { return nil }
+	g.Get("/users/:id/posts/:slug", h)
+	for uri, params := range map[string][]interface{}{
+		"/users/:id/posts/:slug": nil,
+		"/users/1/posts/:slug":   {1},
+		"/users/1/posts/a":       {1, "a", "extra"},
+	} {
+		if got := g.URI(h, params...); got != uri {
+			t.Errorf("%v: expected %s, got %s", params, uri, got)
+		}
+	}
+}
+
+func TestRouterCustomMethods(t *testing.T) {
+	g := New()
+	g.Connect("/tunnel", testHandler("connect"))
+	g.Trace("/tunnel", testHandler("trace"))
+	g.Add("PROPFIND", "/dav/*", testHandler("propfind"))
+	g.Match([]string{"MKCOL", "PURGE"}, "/dav/*", testHandler("webdav"))
+
+	checkRoutes(t, g, []routeTest{
+		{CONNECT, "/tunnel", "connect", nil},
+		{TRACE, "/tunnel", "trace", nil},
+		{"PROPFIND", "/dav/a/b", "propfind", map[string]string{"_*": "a/b"}},
+		{"MKCOL", "/dav/c", "webdav", nil},
+		{"PURGE", "/dav/c", "webdav", nil},
+		{"REPORT", "/dav/c", "", nil},
+	})
+
+	rCtx := request(g, "REPORT", "/dav/c")
+	if rCtx.Response.StatusCode() != 405 {
+		t.Errorf("expected 405, got %d", rCtx.Response.StatusCode())
+	}
+
+	// Any keeps answering only the methods it always did.
+	g.Any("/any", testHandler("any"))
+	checkRoutes(t, g, []routeTest{
+		{GET, "/any", "any", nil},
+		{TRACE, "/any", "", nil},
+		{CONNECT, "/any", "", nil},
+	})
+	rCtx = request(g, TRACE, "/any")
+	if allow := string(rCtx.Response.Header.Peek(Allow)); allow != "DELETE, GET, HEAD, OPTIONS, PATCH, POST, PUT" {
+		t.Errorf("unexpected Allow header %q", allow)
+	}
+}
+
+func TestRouterAutoHeadOptions(t *testing.T) {
+	g := New()
+	g.Get("/users", func(c *Context) error {
+		return c.String(fasthttp.StatusOK, "users")
+	})
+	g.Post("/users", testHandler("create"))
+	g.Add("PURGE", "/users", testHandler("purge"))
+
+	rCtx := request(g, HEAD, "/users")
+	if rCtx.Response.StatusCode() != fasthttp.StatusOK || !rCtx.Response.SkipBody {
+		t.Errorf("HEAD: expected 200 without body, got %d skip=%v", rCtx.Response.StatusCode(), rCtx.Response.SkipBody)
+	}
+
+	allow := "GET, HEAD, OPTIONS, POST, PURGE"
+	rCtx = request(g, OPTIONS, "/users")
+	if rCtx.Response.StatusCode() != fasthttp.StatusNoContent {
+		t.Errorf("OPTIONS: expected 204, got %d", rCtx.Response.StatusCode())
+	}
+	if a := string(rCtx.Response.Header.Peek(Allow)); a != allow {
+		t.Errorf("OPTIONS: expected Allow %q, got %q", allow, a)
+	}
+
+	rCtx = request(g, DELETE, "/users")
+	if rCtx.Response.StatusCode() != fasthttp.StatusMethodNotAllowed {
+		t.Errorf("DELETE: expected 405, got %d", rCtx.Response.StatusCode())
+	}
+	if a := string(rCtx.Response.Header.Peek(Allow)); a != allow {
+		t.Errorf("DELETE: expected Allow %q, got %q", allow, a)
+	}
+
+	g.SetAutoHead(false)
+	g.SetAutoOptions(false)
+	for _, m := range []string{HEAD, OPTIONS} {
+		rCtx = request(g, m, "/users")
+		if rCtx.Response.StatusCode() != fasthttp.StatusMethodNotAllowed {
+			t.Errorf("%s: expected 405, got %d", m, rCtx.Response.StatusCode())
+		}
+		if a := string(rCtx.Response.Header.Peek(Allow)); a != "GET, POST, PURGE" {
+			t.Errorf("%s: unexpected Allow %q", m, a)
+		}
+	}
+}
+
+func TestRouterPathPolicies(t *testing.T) {
+	g := New()
+	g.Get("/users", testHandler("users"))
+	g.Get("/posts/", testHandler("posts"))
+	g.Get("/Users/:id/Profile", testHandler("profile"))
+	g.Post("/users", testHandler("create"))
+
+	checkRoutes(t, g, []routeTest{
+		{GET, "/users/", "", nil},
+		{GET, "/posts", "", nil},
+		{GET, "//users", "", nil},
+		{GET, "/users/1/profile", "", nil},
+	})
+
+	g.SetTrailingSlash(TrailingSlashMatch)
+	g.SetCleanPath(true)
+	g.SetCaseInsensitive(true)
+	checkRoutes(t, g, []routeTest{
+		{GET, "/users/", "users", nil},
+		{GET, "/posts", "posts", nil},
+		{GET, "//users/../users", "users", nil},
+		{GET, "/users/Abc/PROFILE", "profile", map[string]string{"id": "Abc"}},
+	})
+
+	g.SetTrailingSlash(TrailingSlashRedirect)
+	g.SetRedirectFixedPath(true)
+	tests := []struct {
+		method, path, location string
+		code                   int
+	}{
+		{GET, "/users/?page=2", "/users?page=2", fasthttp.StatusMovedPermanently},
+		{POST, "/users/", "/users", fasthttp.StatusPermanentRedirect},
+		{GET, "/posts", "/posts/", fasthttp.StatusMovedPermanently},
+		{GET, "/users/Abc/PROFILE/", "/Users/Abc/Profile", fasthttp.StatusMovedPermanently},
+	}
+	for _, tt := range tests {
+		rCtx := request(g, tt.method, tt.path)
+		if rCtx.Response.StatusCode() != tt.code {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.path, tt.code, rCtx.Response.StatusCode())
+		}
+		if l := string(rCtx.Response.Header.Peek(Location)); !strings.HasSuffix(l, tt.location) {
+			t.Errorf("%s %s: expected location %s, got %s", tt.method, tt.path, tt.location, l)
+		}
+	}
+}
+
+func TestRouterFindCleanPathRedirect(t *testing.T) {
+	g := New()
+	g.Get("/users", testHandler("users"))
+	g.SetCleanPath(true)
+	g.SetRedirectFixedPath(true)
+
+	// fasthttp normalizes request paths itself, so call Find directly.
+	rCtx := new(fasthttp.RequestCtx)
+	rCtx.Request.SetRequestURI("/")
+	c := NewContext(rCtx, g)
+	h, _ := g.router.Find(GET, "//a/../users", c)
+	if err := h(c); err != nil {
+		t.Fatal(err)
+	}
+	if rCtx.Response.StatusCode() != fasthttp.StatusMovedPermanently {
+		t.Errorf("expected 301, got %d", rCtx.Response.StatusCode())
+	}
+	if l := string(rCtx.Response.Header.Peek(Location)); !strings.HasSuffix(l, "/users") {
+		t.Errorf("expected location /users, got %s", l)
+	}
+}
+
+func TestRouterFindAllocs(t *testing.T) {
+	g := New()
+	g.Get("/users/:id", testHandler("users.show"))
+	g.Get("/static/about", testHandler("about"))
+	g.SetTrailingSlash(TrailingSlashMatch)
+	g.SetCleanPath(true)
+	g.SetCaseInsensitive(true)
+	c := NewContext(nil, g)
+	// Only the copy of the param values allocates.
+	for path, allocs := range map[string]float64{"/users/1": 1, "/static/about": 0, "/users/1/": 1, "/STATIC/about": 0} {
+		if n := testing.AllocsPerRun(100, func() { g.router.Find(GET, path, c) }); n != allocs {
+			t.Errorf("%s: expected %v allocations, got %v", path, allocs, n)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package goka
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/valyala/fasthttp"
+)
+
+type Config struct {
+	Name               string
+	Concurrency        int
+	ReadBufferSize     int
+	WriteBufferSize    int
+	ReadTimeout        time.Duration
+	WriteTimeout       time.Duration
+	IdleTimeout        time.Duration
+	MaxConnsPerIP      int
+	MaxRequestsPerConn int
+	MaxRequestBodySize int
+	ReduceMemoryUsage  bool
+}
+
+// unixListener is a Unix socket listener moved to addr after it was
+// created. Like net.UnixListener it removes the socket when closed.
+type unixListener struct {
+	*net.UnixListener
+	addr *net.UnixAddr
+}
+
+// onceCloseListener lets both Shutdown and the server close the listener.
+type onceCloseListener struct {
+	net.Listener
+	once sync.Once
+	err  error
+}
+
+var (
+	ErrServerNotRunning = errors.New("server not running")
+	ErrServerRunning    = errors.New("server already running")
+)
+
+// SetConfig sets the fields of the server returned by Server from c,
+// keeping other changes made to it. A running server is updated when it is
+// next started.
+func (g *Goka) SetConfig(c Config) {
+	g.mu.Lock()
+	g.config = c
+	if g.running {
+		g.configPending = true
+	} else if g.server != nil {
+		c.apply(g.server)
+	}
+	g.mu.Unlock()
+}
+
+func (g *Goka) Config() Config {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.config
+}
+
+// Server returns the server used by Start and Run, built from the config on
+// first use. It may be tuned further before the server is started.
+func (g *Goka) Server() *fasthttp.Server {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.server == nil {
+		g.server = g.newServer()
+	}
+	return g.server
+}
+
+func (g *Goka) OnStart(f ...func()) {
+	g.onStart = append(g.onStart, f...)
+}
+
+func (g *Goka) OnShutdown(f ...func()) {
+	g.onShutdown = append(g.onShutdown, f...)
+}
+
+func (g *Goka) Start(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return g.serve(ln, (*fasthttp.Server).Serve)
+}
+
+// StartTLS is like Start but serves HTTPS. The certificate and key are
+// loaded before the server starts.
+func (g *Goka) StartTLS(addr, certFile, keyFile string) error {
+	cert, err := os.ReadFile(certFile)
+	if err != nil {
+		return err
+	}
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		return err
+	}
+	if _, err = tls.X509KeyPair(cert, key); err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return g.serve(ln, func(s *fasthttp.Server, ln net.Listener) error {
+		return s.ServeTLSEmbed(ln, cert, key)
+	})
+}
+
+func (g *Goka) Run(addr string) error {
+	return g.Start(addr)
+}
+
+func (g *Goka) RunTLS(addr, certFile, keyFile string) error {
+	return g.StartTLS(addr, certFile, keyFile)
+}
+
+func (g *Goka) RunListener(ln net.Listener) error {
+	return g.serve(ln, (*fasthttp.Server).Serve)
+}
+
+// RunUnix serves on a Unix domain socket created at path with the
+// permissions mode. A socket left at path by an earlier run is replaced,
+// but any other file there is an error.
+func (g *Goka) RunUnix(path string, mode os.FileMode) error {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return fmt.Errorf("goka: %s exists and is not a socket", path)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	ln, err := listenUnix(path, mode)
+	if err != nil {
+		return err
+	}
+	return g.RunListener(ln)
+}
+
+// listenUnix creates the socket in a private directory next to path and
+// moves it into place once its mode is set, so that it is never reachable
+// with the default permissions.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".goka")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, "sock")
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmp, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	ln.SetUnlinkOnClose(false)
+	if err = os.Chmod(tmp, mode); err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return &unixListener{UnixListener: ln, addr: &net.UnixAddr{Name: path, Net: "unix"}}, nil
+}
+
+// Addr returns the address the server is listening on, or nil when the
+// server is not running.
+func (g *Goka) Addr() net.Addr {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.listener == nil {
+		return nil
+	}
+	return g.listener.Addr()
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests
+// until ctx is done.
+func (g *Goka) Shutdown(ctx context.Context) error {
+	g.mu.Lock()
+	if !g.running {
+		g.mu.Unlock()
+		return ErrServerNotRunning
+	}
+	s, ln := g.server, g.listener
+	g.running = false
+	g.listener = nil
+	g.mu.Unlock()
+
+	for _, f := range g.onShutdown {
+		f()
+	}
+
+	// Closing the listener here also stops a Serve that has not started
+	// accepting yet, which the server itself would not know about.
+	ln.Close()
+	return s.ShutdownWithContext(ctx)
+}
+
+// serve starts the server on ln and runs serve, clearing the running state
+// once serve returns.
+func (g *Goka) serve(ln net.Listener, serve func(*fasthttp.Server, net.Listener) error) error {
+	s, ln, err := g.startServer(ln)
+	if err != nil {
+		return err
+	}
+	defer g.stopServer(ln)
+	return serve(s, ln)
+}
+
+func (g *Goka) startServer(ln net.Listener) (*fasthttp.Server, net.Listener, error) {
+	g.mu.Lock()
+	if g.running {
+		g.mu.Unlock()
+		ln.Close()
+		return nil, nil, ErrServerRunning
+	}
+	if g.server == nil {
+		g.server = g.newServer()
+	} else if g.configPending {
+		g.config.apply(g.server)
+	}
+	g.configPending = false
+	s := g.server
+	ln = &onceCloseListener{Listener: ln}
+	g.running = true
+	g.listener = ln
+	g.mu.Unlock()
+
+	for _, f := range g.onStart {
+		f()
+	}
+	return s, ln, nil
+}
+
+// stopServer closes ln and, unless Shutdown already did, marks the server
+// as no longer running.
+func (g *Goka) stopServer(ln net.Listener) {
+	ln.Close()
+	g.mu.Lock()
+	if g.listener == ln {
+		g.running = false
+		g.listener = nil
+	}
+	g.mu.Unlock()
+}
+
+func (g *Goka) newServer() *fasthttp.Server {
+	s := &fasthttp.Server{Handler: g.Serve}
+	g.config.apply(s)
+	return s
+}
+
+func (c Config) apply(s *fasthttp.Server) {
+	s.Name = c.Name
+	s.Concurrency = c.Concurrency
+	s.ReadBufferSize = c.ReadBufferSize
+	s.WriteBufferSize = c.WriteBufferSize
+	s.ReadTimeout = c.ReadTimeout
+	s.WriteTimeout = c.WriteTimeout
+	s.IdleTimeout = c.IdleTimeout
+	s.MaxConnsPerIP = c.MaxConnsPerIP
+	s.MaxRequestsPerConn = c.MaxRequestsPerConn
+	s.MaxRequestBodySize = c.MaxRequestBodySize
+	s.ReduceMemoryUsage = c.ReduceMemoryUsage
+}
+
+func (l *onceCloseListener) Close() error {
+	l.once.Do(func() { l.err = l.Listener.Close() })
+	return l.err
+}
+
+func (l *unixListener) Addr() net.Addr {
+	return l.addr
+}
+
+func (l *unixListener) Close() error {
+	err := l.UnixListener.Close()
+	os.Remove(l.addr.Name)
+	return err
+}
@@ -0,0 +1,217 @@
+package goka
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestGokaStartShutdown(t *testing.T) {
+	g := New()
+	started := make(chan struct{})
+	stopped := false
+	g.OnStart(func() { close(started) })
+	g.OnShutdown(func() { stopped = true })
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- g.Start("127.0.0.1:0")
+	}()
+
+	select {
+	case <-started:
+	case err := <-errc:
+		t.Fatalf("start: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := g.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+	if !stopped {
+		t.Error("OnShutdown hook not called")
+	}
+	if err := g.Shutdown(ctx); err != ErrServerNotRunning {
+		t.Errorf("expected ErrServerNotRunning, got %v", err)
+	}
+}
+
+func TestGokaShutdownOnStart(t *testing.T) {
+	g := New()
+	g.OnStart(func() {
+		if err := g.Shutdown(context.Background()); err != nil {
+			t.Errorf("shutdown: %v", err)
+		}
+	})
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- g.Start("127.0.0.1:0")
+	}()
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Errorf("serve: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("server still running after shutdown")
+	}
+}
+
+func TestGokaStartTLSFailure(t *testing.T) {
+	g := New()
+	started := false
+	g.OnStart(func() { started = true })
+	if err := g.StartTLS("127.0.0.1:0", "/nonexistent.crt", "/nonexistent.key"); err == nil {
+		t.Fatal("expected an error for a missing certificate")
+	}
+	if started {
+		t.Error("OnStart hook called for a server that did not start")
+	}
+	if g.Addr() != nil {
+		t.Errorf("expected no address, got %v", g.Addr())
+	}
+}
+
+func TestGokaServeReturns(t *testing.T) {
+	g := New()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+	g.RunListener(ln)
+	if g.Addr() != nil {
+		t.Errorf("expected no address, got %v", g.Addr())
+	}
+	if err := g.Shutdown(context.Background()); err != ErrServerNotRunning {
+		t.Errorf("expected ErrServerNotRunning, got %v", err)
+	}
+}
+
+func TestGokaServerConfig(t *testing.T) {
+	g := New()
+	g.SetConfig(Config{
+		Name:               "goka",
+		ReadTimeout:        5 * time.Second,
+		MaxRequestBodySize: 1 << 20,
+	})
+	s := g.Server()
+	if s.Name != "goka" || s.ReadTimeout != 5*time.Second || s.MaxRequestBodySize != 1<<20 {
+		t.Errorf("config not applied: %+v", s)
+	}
+	if g.Server() != s {
+		t.Error("expected the same server before start")
+	}
+
+	s.DisableKeepalive = true
+	g.SetConfig(Config{Name: "renamed"})
+	if g.Server() != s || s.Name != "renamed" || s.ReadTimeout != 0 || !s.DisableKeepalive {
+		t.Errorf("expected the config to update the server and keep other changes, got %q %v %v", s.Name, s.ReadTimeout, s.DisableKeepalive)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		g.SetConfig(Config{Name: "concurrent"})
+		close(done)
+	}()
+	g.Config()
+	<-done
+}
+
+func TestGokaRunListener(t *testing.T) {
+	g := New()
+	g.Get("/ping", func(c *Context) error {
+		return c.String(fasthttp.StatusOK, "pong")
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	g.OnStart(func() { close(started) })
+	go g.RunListener(ln)
+	<-started
+	defer g.Shutdown(context.Background())
+
+	if g.Addr().String() != ln.Addr().String() {
+		t.Fatalf("expected %s, got %s", ln.Addr(), g.Addr())
+	}
+	code, body, err := fasthttp.Get(nil, "http://"+g.Addr().String()+"/ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != fasthttp.StatusOK || string(body) != "pong" {
+		t.Errorf("unexpected response %d %q", code, body)
+	}
+}
+
+func TestGokaRunUnix(t *testing.T) {
+	g := New()
+	g.Get("/ping", func(c *Context) error {
+		return c.String(fasthttp.StatusOK, "pong")
+	})
+	dir := t.TempDir()
+	path := filepath.Join(dir, "goka.sock")
+	// A socket left by an earlier run is replaced.
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+
+	started := make(chan struct{})
+	g.OnStart(func() { close(started) })
+	go g.RunUnix(path, 0600)
+	<-started
+	defer g.Shutdown(context.Background())
+
+	c := &fasthttp.HostClient{
+		Addr: path,
+		Dial: func(addr string) (net.Conn, error) {
+			return net.Dial("unix", addr)
+		},
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("expected socket mode 0600, got %v %v", fi, err)
+	}
+	code, body, err := c.Get(nil, "http://goka/ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != fasthttp.StatusOK || string(body) != "pong" {
+		t.Errorf("unexpected response %d %q", code, body)
+	}
+	if g.Addr().String() != path {
+		t.Errorf("expected %s, got %s", path, g.Addr())
+	}
+
+	g.Shutdown(context.Background())
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Errorf("expected the socket to be removed, got %v %v", entries, err)
+	}
+}
+
+func TestGokaRunUnixExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "goka.sock")
+	if err := os.WriteFile(path, []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := New().RunUnix(path, 0600); err == nil {
+		t.Fatal("expected an error for a file that is not a socket")
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "data" {
+		t.Errorf("expected the file to be kept, got %q %v", data, err)
+	}
+}
@@ -0,0 +1,66 @@
+package goka
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+type UUID [16]byte
+
+var (
+	errInvalidUUID = errors.New("invalid UUID")
+
+	uuidOffsets = [16]int{0, 2, 4, 6, 9, 11, 14, 16, 19, 21, 24, 26, 28, 30, 32, 34}
+)
+
+func ParseUUID(s string) (u UUID, err error) {
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, errInvalidUUID
+	}
+	for i, o := range uuidOffsets {
+		if _, err = hex.Decode(u[i:i+1], []byte(s[o:o+2])); err != nil {
+			return u, errInvalidUUID
+		}
+	}
+	return
+}
+
+// NewUUIDv4 returns a random (version 4) UUID.
+func NewUUIDv4() (u UUID) {
+	rand.Read(u[:])
+	u.setVersion(4)
+	return
+}
+
+// NewUUIDv7 returns a time-ordered (version 7) UUID whose first 48 bits
+// are the Unix time in milliseconds.
+func NewUUIDv7() (u UUID) {
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(time.Now().UnixMilli()))
+	copy(u[:6], ms[2:])
+	rand.Read(u[6:])
+	u.setVersion(7)
+	return
+}
+
+func (u *UUID) setVersion(v byte) {
+	u[6] = u[6]&0x0f | v<<4
+	u[8] = u[8]&0x3f | 0x80
+}
+
+func (u UUID) String() string {
+	var b [36]byte
+	hex.Encode(b[0:8], u[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], u[10:])
+	return string(b[:])
+}
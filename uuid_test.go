@@ -0,0 +1,33 @@
+package goka
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewUUID(t *testing.T) {
+	for _, tt := range []struct {
+		version byte
+		new     func() UUID
+	}{
+		{4, NewUUIDv4},
+		{7, NewUUIDv7},
+	} {
+		u := tt.new()
+		if u[6]>>4 != tt.version || u[8]&0xc0 != 0x80 {
+			t.Errorf("v%d: wrong version or variant in %s", tt.version, u)
+		}
+		if p, err := ParseUUID(u.String()); err != nil || p != u {
+			t.Errorf("v%d: %s does not round-trip: %v", tt.version, u, err)
+		}
+		if tt.new() == u {
+			t.Errorf("v%d: expected distinct UUIDs", tt.version)
+		}
+	}
+
+	u := NewUUIDv7()
+	ms := int64(u[0])<<40 | int64(u[1])<<32 | int64(u[2])<<24 | int64(u[3])<<16 | int64(u[4])<<8 | int64(u[5])
+	if d := time.Since(time.UnixMilli(ms)); d < 0 || d > time.Minute {
+		t.Errorf("unexpected v7 timestamp %v", time.UnixMilli(ms))
+	}
+}
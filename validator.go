@@ -0,0 +1,267 @@
+package goka
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+type (
+	FieldError struct {
+		Field   string `json:"field"`
+		Tag     string `json:"tag"`
+		Param   string `json:"param,omitempty"`
+		Message string `json:"message"`
+	}
+
+	ValidationErrors []*FieldError
+
+	rule struct {
+		tag   string
+		param string
+		n     float64
+	}
+
+	fieldRules struct {
+		index []int
+		name  string
+		rules []rule
+		dive  bool
+	}
+)
+
+var (
+	validatorCache sync.Map
+
+	emailRegexp = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+)
+
+func (e ValidationErrors) Error() string {
+	s := make([]string, len(e))
+	for i, fe := range e {
+		s[i] = fe.Message
+	}
+	return strings.Join(s, "; ")
+}
+
+// ValidateStruct checks the validate tags of the struct i points to and
+// returns ValidationErrors listing every failing field.
+func ValidateStruct(i interface{}) error {
+	v := reflect.ValueOf(i)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	var errs ValidationErrors
+	validateStruct(v, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateStruct(v reflect.Value, prefix string, errs *ValidationErrors) {
+	for _, fr := range cachedRules(v.Type()) {
+		f := v.FieldByIndex(fr.index)
+		name := prefix + fr.name
+		for _, r := range fr.rules {
+			if r.tag == "omitempty" {
+				if isZero(f) {
+					break
+				}
+				continue
+			}
+			if !r.check(f) {
+				*errs = append(*errs, &FieldError{
+					Field:   name,
+					Tag:     r.tag,
+					Param:   r.param,
+					Message: r.message(name),
+				})
+				break
+			}
+		}
+		if fr.dive {
+			for f.Kind() == reflect.Ptr && !f.IsNil() {
+				f = f.Elem()
+			}
+			if f.Kind() == reflect.Struct {
+				validateStruct(f, name+".", errs)
+			}
+		}
+	}
+}
+
+func cachedRules(t reflect.Type) []fieldRules {
+	if frs, ok := validatorCache.Load(t); ok {
+		return frs.([]fieldRules)
+	}
+	frs := parseRules(t, nil)
+	validatorCache.Store(t, frs)
+	return frs
+}
+
+func parseRules(t reflect.Type, index []int) (frs []fieldRules) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		idx := append(append([]int(nil), index...), i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			frs = append(frs, parseRules(sf.Type, idx)...)
+			continue
+		}
+		tag := sf.Tag.Get("validate")
+		if tag == "-" {
+			continue
+		}
+		ft := sf.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		fr := fieldRules{
+			index: idx,
+			name:  fieldName(sf),
+			dive:  ft.Kind() == reflect.Struct && ft != timeType,
+		}
+		if tag != "" {
+			for _, r := range strings.Split(tag, ",") {
+				fr.rules = append(fr.rules, parseRule(r))
+			}
+		}
+		if len(fr.rules) > 0 || fr.dive {
+			frs = append(frs, fr)
+		}
+	}
+	return
+}
+
+func parseRule(s string) rule {
+	kv := strings.SplitN(s, "=", 2)
+	r := rule{tag: kv[0]}
+	if len(kv) == 2 {
+		r.param = kv[1]
+	}
+	switch r.tag {
+	case "required", "omitempty", "email", "oneof":
+	case "min", "max", "len":
+		n, err := strconv.ParseFloat(r.param, 64)
+		if err != nil {
+			panic("goka => invalid validate param " + s)
+		}
+		r.n = n
+	default:
+		panic("goka => unknown validate tag " + s)
+	}
+	return r
+}
+
+func fieldName(sf reflect.StructField) string {
+	if n := strings.Split(sf.Tag.Get("json"), ",")[0]; n != "" && n != "-" {
+		return n
+	}
+	return sf.Name
+}
+
+func (r rule) check(v reflect.Value) bool {
+	if r.tag == "required" {
+		return !isZero(v)
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return true
+		}
+		v = v.Elem()
+	}
+	switch r.tag {
+	case "min", "max", "len":
+		size, ok := size(v)
+		if !ok {
+			return true
+		}
+		switch r.tag {
+		case "min":
+			return size >= r.n
+		case "max":
+			return size <= r.n
+		default:
+			return size == r.n
+		}
+	case "email":
+		return v.Kind() != reflect.String || v.Len() == 0 || emailRegexp.MatchString(v.String())
+	case "oneof":
+		s := valueString(v)
+		for _, o := range strings.Fields(r.param) {
+			if s == o {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func (r rule) message(field string) string {
+	switch r.tag {
+	case "required":
+		return field + " is required"
+	case "min":
+		return field + " must be at least " + r.param
+	case "max":
+		return field + " must be at most " + r.param
+	case "len":
+		return field + " must have length " + r.param
+	case "email":
+		return field + " must be a valid email address"
+	case "oneof":
+		return field + " must be one of [" + r.param + "]"
+	}
+	return field + " is invalid"
+}
+
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+func size(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+func valueString(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	}
+	return ""
+}
@@ -0,0 +1,74 @@
+package goka
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+type signup struct {
+	Name    string   `json:"name" validate:"required,min=1,max=8"`
+	Email   string   `json:"email" validate:"required,email"`
+	Plan    string   `json:"plan" validate:"oneof=free pro"`
+	Age     int      `json:"age" validate:"omitempty,min=18"`
+	Tags    []string `json:"tags" validate:"max=2"`
+	Address struct {
+		City string `json:"city" validate:"required"`
+	} `json:"address"`
+}
+
+func TestValidateStruct(t *testing.T) {
+	s := &signup{Name: "goka", Email: "goka@example.com", Plan: "pro"}
+	s.Address.City = "Tokyo"
+	if err := ValidateStruct(s); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s = &signup{Name: "gokagokagoka", Email: "goka", Plan: "gold", Age: 3, Tags: []string{"a", "b", "c"}}
+	err := ValidateStruct(s)
+	ve, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("expected ValidationErrors, got %v", err)
+	}
+	expected := []string{"name:max", "email:email", "plan:oneof", "age:min", "tags:max", "address.city:required"}
+	if len(ve) != len(expected) {
+		t.Fatalf("expected %d errors, got %v", len(expected), ve)
+	}
+	for i, fe := range ve {
+		if fe.Field+":"+fe.Tag != expected[i] {
+			t.Errorf("expected %s, got %s:%s", expected[i], fe.Field, fe.Tag)
+		}
+	}
+}
+
+func TestBindValidationErrorHandler(t *testing.T) {
+	g := New()
+	g.Post("/signup", func(c *Context) error {
+		s := new(signup)
+		if err := c.Bind(s); err != nil {
+			return err
+		}
+		return c.NoContent(fasthttp.StatusCreated)
+	})
+
+	rCtx := new(fasthttp.RequestCtx)
+	rCtx.Request.Header.SetMethod(POST)
+	rCtx.Request.SetRequestURI("/signup")
+	rCtx.Request.Header.SetContentType(ApplicationJSON)
+	rCtx.Request.SetBodyString(`{"email":"goka@example.com","plan":"free","address":{"city":"Tokyo"}}`)
+	g.Serve(rCtx)
+
+	if rCtx.Response.StatusCode() != fasthttp.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", rCtx.Response.StatusCode())
+	}
+	var body struct {
+		Errors []FieldError `json:"errors"`
+	}
+	if err := json.Unmarshal(rCtx.Response.Body(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Errors) != 1 || body.Errors[0].Field != "name" || body.Errors[0].Tag != "required" {
+		t.Errorf("unexpected body %s", rCtx.Response.Body())
+	}
+}